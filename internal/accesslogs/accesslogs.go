@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"strconv"
 
-	"slackLogs/internal/args"
 	"slackLogs/internal/common"
 	"slackLogs/internal/logclient"
 	"slackLogs/internal/model"
@@ -26,10 +25,11 @@ var collectedLogs bool
 
 type accessLogsHandler struct {
 	Client *logclient.LogClient
+	PollingInterval time.Duration
 }
 
-func NewAccessLogsHandler(client *logclient.LogClient) *accessLogsHandler {
-	return &accessLogsHandler{Client: client}
+func NewAccessLogsHandler(client *logclient.LogClient, pollingInterval time.Duration) *accessLogsHandler {
+	return &accessLogsHandler{Client: client, PollingInterval: pollingInterval}
 }
 
 // teamAccessLogResponse contains slack API successful response
@@ -61,7 +61,7 @@ func getSlackaccessLogs(c *common.SlackClient, before int64, teamId string) (tea
 	return responseData, nil
 }
 
-func transformaccessLogs(accessLogs []model.AccessLog, teamName string, lastTimeStamp int64) error {
+func transformaccessLogs(client *logclient.LogClient, accessLogs []model.AccessLog, teamName string, lastTimeStamp int64) error {
 	ts := time.Now().Unix()
 	for _, l := range accessLogs {
 		if l.DateLast < lastTimeStamp {
@@ -73,14 +73,14 @@ func transformaccessLogs(accessLogs []model.AccessLog, teamName string, lastTime
 		}
 		l.TeamName = teamName
 		data, errJson := json.Marshal(l)
-		totalLogsSize = totalLogsSize + len(data)
 		if errJson != nil {
 			return errJson
 		}
-		lm := logclient.Logs{
-			Timestamp: ts,
-			Message:   string(data),
+		lm, keep := client.NewLogs(logtype, ts, data)
+		if !keep {
+			continue
 		}
+		totalLogsSize = totalLogsSize + len(lm.Message)
 		logCount = logCount + 1
 		logs = append(logs, lm)
 	}
@@ -97,7 +97,7 @@ func (al *accessLogsHandler) ResetLogs() {
 }
 
 func (al *accessLogsHandler) Collect(token string, teamId string, teamName string) error {
-	flushInterval := args.GetAccessLogsPollingInterval()
+	flushInterval := al.PollingInterval
 	nextCursor := ""
 	logCount = 0
 	slackToken = token
@@ -113,7 +113,7 @@ func (al *accessLogsHandler) Collect(token string, teamId string, teamName strin
 			return err
 		}
 		// Filter required fields and add timestamp to each log
-		err = transformaccessLogs(response.AccessList, teamName, lastBeforeFetched)
+		err = transformaccessLogs(al.Client, response.AccessList, teamName, lastBeforeFetched)
 		if err != nil {
 			return err
 		}
@@ -11,10 +11,27 @@ import (
 	"time"
 	"io/ioutil"
 	"gopkg.in/yaml.v3"
+
+	"slackLogs/internal/channelfilter"
+	"slackLogs/internal/constants"
+	"slackLogs/internal/logging"
+	"slackLogs/internal/projection"
+	"slackLogs/internal/redaction"
+	"slackLogs/internal/secrets"
+)
+
+// CheckScopesCommand reports the token scopes against the enabled collectors and exits
+const CheckScopesCommand = "check-scopes"
+
+// Allowed values of global.scopePreflight
+const (
+	ScopePreflightOff     = "off"
+	ScopePreflightWarn    = "warn"
+	ScopePreflightDisable = "disable"
 )
 
 var (
-	nrAccount       string
+	nrAccount       *secrets.Secret
 	nrUrlLog        string
 	fetchAccessLogs bool
 	fetchChannelDetails  bool
@@ -28,16 +45,205 @@ var (
 	accessLogsPollingInterval time.Duration
 	logLevel   string
 	flushLogSize   int64
+	scopePreflight string
+	tracePayloads  bool
+	tokenStorePath string
+	stateDirectory string
+	rateLimitPerMinute int
+	workspaces     []Workspace
+	events         Events
+	socketMode     SocketMode
+	channelMembershipFilter *channelfilter.Filter
+	conversationLogsFilter *channelfilter.Filter
+	reconcileWindow time.Duration
+	threadWindow    time.Duration
+	enrichment      EnrichmentConfig
+	rendering       RenderingConfig
+	redactionConfig redaction.Config
+	redactionSalt   *secrets.Secret // nil without a salt
+	projectionRules map[string]projection.Rules
+	autoJoin        bool
+	channelTypes   []string
+	conversationTypes []string
+	membershipTypes   []string
+	excludeArchived bool
+	sessionPolicy  SessionPolicy
+	command        string
 )
 
 // Config struct to match the structure of the YAML file
 type Config struct {
         Global             GlobalConfig          `yaml:"global"`
-        ConversationLogs   LogsAttributes        `yaml:"conversationLogs"`
-        ChannelDetails     LogsAttributes        `yaml:"channelDetails"`
+        ConversationLogs   ConversationLogsConfig `yaml:"conversationLogs"`
+        ChannelDetails     ChannelDetailsConfig  `yaml:"channelDetails"`
         UserLogs           LogsAttributes        `yaml:"userLogs"`
         AccessLogs         LogsAttributes        `yaml:"accessLogs"`
         AuditLogs          LogsAttributes        `yaml:"auditLogs"`
+	IntegrationLogs    LogsAttributes        `yaml:"integrationLogs"`
+	ChannelMembership  ChannelMembershipConfig `yaml:"channelMembership"`
+	FileLogs           LogsAttributes        `yaml:"fileLogs"`
+	UserGroups         LogsAttributes        `yaml:"userGroups"`
+	Grid               GridConfig            `yaml:"grid"`
+	Workspaces         []WorkspaceConfig     `yaml:"workspaces"`
+	Events             EventsConfig          `yaml:"events"`
+	Enrichment         EnrichmentConfig      `yaml:"enrichment"`
+	Rendering          RenderingConfig       `yaml:"rendering"`
+	Redaction          redaction.Config      `yaml:"redaction"`
+	LogTypes           map[string]projection.Rules `yaml:"logtypes"` // Field projection and drop rules per logtype
+	SocketMode         SocketModeConfig      `yaml:"socketMode"`
+}
+
+// ChannelDetailsConfig is the channelDetails block, it also configures channel discovery for the other collectors
+type ChannelDetailsConfig struct {
+	LogsAttributes  `yaml:",inline"`
+	Types           []string `yaml:"types"` // conversations.list types, public_channel when empty
+	ExcludeArchived bool     `yaml:"excludeArchived"`
+}
+
+// ConversationLogsConfig is the conversationLogs block
+type ConversationLogsConfig struct {
+	LogsAttributes      `yaml:",inline"`
+	ReconcileWindow     string   `yaml:"reconcileWindow"` // Window re-fetched to find edits and deletions, disabled when empty
+	ThreadWindow        string   `yaml:"threadWindow"`    // New replies are collected for threads started within this window
+	AutoJoin            bool     `yaml:"autoJoin"`        // Join selected public channels the token is not a member of
+	channelfilter.Rules `yaml:",inline"`
+}
+
+// ChannelMembershipConfig is the channelMembership block, its include/exclude rules limit the collected channels
+type ChannelMembershipConfig struct {
+	LogsAttributes      `yaml:",inline"`
+	channelfilter.Rules `yaml:",inline"`
+}
+
+// SocketModeConfig configures the optional Socket Mode event source
+type SocketModeConfig struct {
+	Enabled            bool   `yaml:"enabled"`
+	AppToken           string `yaml:"appToken"` // Secret reference, defaults to SLACK_APP_TOKEN(_FILE)
+	ConnectionsOpenURL string `yaml:"connectionsOpenURL"`
+}
+
+// SocketMode holds the parsed Socket Mode configuration
+type SocketMode struct {
+	Enabled            bool
+	AppToken           *secrets.Secret
+	ConnectionsOpenURL string
+}
+
+// EnrichmentConfig configures the resolution of user and channel ids to names in exported logs
+type EnrichmentConfig struct {
+	Enabled         bool `yaml:"enabled"`
+	RewriteMentions bool `yaml:"rewriteMentions"` // Rewrites <@U123> and <#C123|name> in message text
+	IncludeEmail    bool `yaml:"includeEmail"`    // Adds user_email, which is personal data, next to user_name
+}
+
+// RenderingConfig configures the plain text rendering of message blocks and mrkdwn
+type RenderingConfig struct {
+	Enabled    bool `yaml:"enabled"`
+	DropBlocks bool `yaml:"dropBlocks"` // Leaves the raw blocks out of rendered messages
+}
+
+// EventsConfig configures the optional Slack Events API receiver
+type EventsConfig struct {
+	Enabled       bool   `yaml:"enabled"`
+	ListenAddress string `yaml:"listenAddress"`
+	Path          string `yaml:"path"`
+	SigningSecret string `yaml:"signingSecret"` // Secret reference, defaults to SLACK_SIGNING_SECRET(_FILE)
+	FlushInterval string `yaml:"flushInterval"`
+}
+
+// Events holds the parsed events configuration
+type Events struct {
+	Enabled       bool
+	ListenAddress string
+	Path          string
+	SigningSecret *secrets.Secret
+	FlushInterval time.Duration
+}
+
+// WorkspaceConfig is an entry of the optional workspaces list. Collector blocks
+// which are left out inherit the top level block of the same name.
+type WorkspaceConfig struct {
+	Name             string            `yaml:"name"`
+	// Credentials are named by an environment variable (which also honors NAME_FILE),
+	// or given as a secret reference: env://NAME or file:///path/to/secret
+	TokenEnv         string            `yaml:"tokenEnv"`
+	RefreshTokenEnv  string            `yaml:"refreshTokenEnv"`
+	ClientIdEnv      string            `yaml:"clientIdEnv"`
+	ClientSecretEnv  string            `yaml:"clientSecretEnv"`
+	Token            string            `yaml:"token"`
+	RefreshToken     string            `yaml:"refreshToken"`
+	ClientId         string            `yaml:"clientId"`
+	ClientSecret     string            `yaml:"clientSecret"`
+	TokenStorePath   string            `yaml:"tokenStorePath"`
+	Attributes       map[string]string `yaml:"attributes"`
+	ConversationLogs *LogsAttributes   `yaml:"conversationLogs"`
+	ChannelDetails   *LogsAttributes   `yaml:"channelDetails"`
+	UserLogs         *LogsAttributes   `yaml:"userLogs"`
+	AccessLogs       *LogsAttributes   `yaml:"accessLogs"`
+	AuditLogs        *LogsAttributes   `yaml:"auditLogs"`
+	IntegrationLogs  *LogsAttributes   `yaml:"integrationLogs"`
+	ChannelMembership *LogsAttributes  `yaml:"channelMembership"`
+	FileLogs         *LogsAttributes   `yaml:"fileLogs"`
+	UserGroups       *LogsAttributes   `yaml:"userGroups"`
+	Grid             *GridConfig       `yaml:"grid"`
+}
+
+// GridConfig configures the Enterprise Grid collectors, which use the admin.* methods of an org-level token
+type GridConfig struct {
+	Teams         *LogsAttributes `yaml:"teams"`
+	Users         *LogsAttributes `yaml:"users"`
+	Conversations *LogsAttributes `yaml:"conversations"`
+	Apps          *LogsAttributes `yaml:"apps"`
+	Sessions      *SessionsConfig `yaml:"sessions"`
+}
+
+// SessionsConfig is the grid.sessions block
+type SessionsConfig struct {
+	LogsAttributes   `yaml:",inline"`
+	AllowedCountries []string `yaml:"allowedCountries"` // Country codes, sessions from other countries are flagged
+	GetSettings      bool     `yaml:"getSettings"`      // Read session durations to estimate when sessions expire
+}
+
+// SessionPolicy holds the parsed review settings of the Grid sessions collector
+type SessionPolicy struct {
+	AllowedCountries []string
+	GetSettings      bool
+}
+
+// collectors maps the Grid collector names to their blocks
+func (gc *GridConfig) collectors() map[string]*LogsAttributes {
+	if gc == nil {
+		return nil
+	}
+	var sessions *LogsAttributes
+	if gc.Sessions != nil {
+		sessions = &gc.Sessions.LogsAttributes
+	}
+	return map[string]*LogsAttributes{
+		constants.GridTeamsCollector:         gc.Teams,
+		constants.GridUsersCollector:         gc.Users,
+		constants.GridConversationsCollector: gc.Conversations,
+		constants.GridAppsCollector:          gc.Apps,
+		constants.GridSessionsCollector:      sessions,
+	}
+}
+
+// CollectorSettings holds the parsed settings of a collector block
+type CollectorSettings struct {
+	Enabled         bool
+	PollingInterval time.Duration
+}
+
+// Workspace is a Slack workspace (or Grid org) collected with its own token
+type Workspace struct {
+	Name            string
+	Token           *secrets.Secret // nil when only token rotation is configured
+	RefreshToken    *secrets.Secret // nil without token rotation
+	ClientId        *secrets.Secret
+	ClientSecret    *secrets.Secret
+	TokenStorePath  string
+	Attributes      map[string]string
+	Collectors      map[string]CollectorSettings // keyed by collector name
 }
 
 type LogsAttributes struct {
@@ -49,6 +255,12 @@ type GlobalConfig struct {
         FlushLogSize     string  `yaml:"flushLogSize"`
         LogLevel         string  `yaml:"logLevel"`
 	LogApiEndpoint   string  `yaml:"logAPIEndPoint"` 
+	ScopePreflight   string  `yaml:"scopePreflight"`
+	TokenStorePath   string  `yaml:"tokenStorePath"`
+	TracePayloads    bool    `yaml:"tracePayloads"` // Log Slack responses and exported payloads at debug level
+	StateDirectory   string  `yaml:"stateDirectory"` // Checkpoints and snapshots are kept here, in memory when empty
+	IngestKey        string  `yaml:"ingestKey"` // Secret reference, e.g. file:///run/secrets/ingest-key
+	RateLimitPerMinute int   `yaml:"rateLimitPerMinute"`
 }
 
 func parseSize(sizeStr string) (int64, error) {
@@ -107,13 +319,8 @@ func parseDuration(durationStr string) (time.Duration, error) {
 }
 
 func init() {
-	if k, ok := os.LookupEnv("INGEST_KEY"); ok {
-		slog.Debug("IngestKey found in env", "key", k)
-		nrAccount = k
-	}
-
-	if nrAccount == "" {
-		log.Fatalln("****  Please set INGEST_KEY. *****")
+	if len(os.Args) > 1 {
+		command = os.Args[1]
 	}
 
 	// Specify the path to YAML config file
@@ -137,8 +344,35 @@ func init() {
                 log.Fatalf("Error parsing MaxSize: %v", err)
         }
 
+	if config.Global.IngestKey != "" {
+		nrAccount, err = secrets.Parse(config.Global.IngestKey)
+	} else {
+		nrAccount, err = secrets.FromEnv("INGEST_KEY")
+	}
+	if err != nil {
+		log.Fatalf("Error reading the ingest key: %v", err)
+	}
+	// check-scopes only talks to Slack, it does not need the ingest key
+	if nrAccount == nil && command != CheckScopesCommand {
+		log.Fatalln("****  Please set INGEST_KEY or INGEST_KEY_FILE. *****")
+	}
+	slog.Debug("IngestKey found", "source", nrAccount.Source())
+
         nrUrlLog = config.Global.LogApiEndpoint
         logLevel = config.Global.LogLevel
+	tracePayloads = config.Global.TracePayloads
+	tokenStorePath = config.Global.TokenStorePath
+	if tokenStorePath == "" {
+		tokenStorePath = "slackTokens.json"
+	}
+	scopePreflight = strings.ToLower(config.Global.ScopePreflight)
+	switch scopePreflight {
+	case "":
+		scopePreflight = ScopePreflightWarn
+	case ScopePreflightOff, ScopePreflightWarn, ScopePreflightDisable:
+	default:
+		log.Fatalf("Error: Please provide allowed scopePreflight (off, warn, disable): %v", config.Global.ScopePreflight)
+	}
 	fetchAccessLogs = config.AccessLogs.Enabled
 	if (fetchAccessLogs) {
 		accessLogsPollingInterval, err = parseDuration(config.AccessLogs.PollingInterval)
@@ -175,10 +409,79 @@ func init() {
 		}
 	}
 
+	rateLimitPerMinute = config.Global.RateLimitPerMinute
+	stateDirectory = config.Global.StateDirectory
+	autoJoin = config.ConversationLogs.AutoJoin
+	threadWindow = 24 * time.Hour
+	if config.ConversationLogs.ThreadWindow != "" {
+		threadWindow, err = parseDuration(config.ConversationLogs.ThreadWindow)
+		if err != nil {
+			log.Fatalf("Error: %v, Please provide allowed threadWindow for ConversationLogs", err)
+		}
+	}
+	if config.ConversationLogs.ReconcileWindow != "" {
+		reconcileWindow, err = parseDuration(config.ConversationLogs.ReconcileWindow)
+		if err != nil {
+			log.Fatalf("Error: %v, Please provide allowed reconcileWindow for ConversationLogs", err)
+		}
+	}
+	if channelMembershipFilter, err = channelfilter.New(config.ChannelMembership.Rules); err != nil {
+		log.Fatalf("Error: Please provide valid channel rules for channelMembership: %v", err)
+	}
+	if conversationLogsFilter, err = channelfilter.New(config.ConversationLogs.Rules); err != nil {
+		log.Fatalf("Error: Please provide valid channel rules for conversationLogs: %v", err)
+	}
+	// The types rule of conversationLogs selects the collected conversation types, public_channel without include
+	for _, t := range parseChannelTypes("conversationLogs", conversationLogsFilter.Types.Include) {
+		if !conversationLogsFilter.Types.Match(t) {
+			continue
+		}
+		conversationTypes = append(conversationTypes, t)
+	}
+	if len(conversationTypes) == 0 {
+		log.Fatalf("Error: Please provide a types rule for conversationLogs which selects at least one type")
+	}
+	channelTypes = parseChannelTypes("channelDetails", config.ChannelDetails.Types)
+	// Channels of the collected conversation types and of the included membership types are always discovered
+	for _, t := range append(append([]string{}, conversationTypes...), channelMembershipFilter.Types.Include...) {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if !containsString(channelTypes, t) {
+			channelTypes = append(channelTypes, t)
+		}
+	}
+	// Memberships are collected for the discovered channels which the types rule of channelMembership selects
+	for _, t := range channelTypes {
+		if channelMembershipFilter.Types.Match(t) {
+			membershipTypes = append(membershipTypes, t)
+		}
+	}
+	excludeArchived = config.ChannelDetails.ExcludeArchived
+	if config.Grid.Sessions != nil {
+		sessionPolicy = SessionPolicy{AllowedCountries: config.Grid.Sessions.AllowedCountries, GetSettings: config.Grid.Sessions.GetSettings}
+	}
+	workspaces = parseWorkspaces(config)
+	events = parseEvents(config.Events, config.SocketMode.Enabled)
+	enrichment = config.Enrichment
+	rendering = config.Rendering
+	redactionConfig = config.Redaction
+	projectionRules = config.LogTypes
+	if redactionConfig.Enabled {
+		if redactionConfig.Salt != "" {
+			redactionSalt, err = secrets.Parse(redactionConfig.Salt)
+		} else {
+			redactionSalt, err = secrets.FromEnv("REDACTION_SALT")
+		}
+		if err != nil {
+			log.Fatalf("Error reading the redaction salt: %v", err)
+		}
+	}
+	socketMode = parseSocketMode(config.SocketMode)
+
 	// Setup slog
 	var programLevel = new(slog.LevelVar) // Info by default
    	h := slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: programLevel})
-   	slog.SetDefault(slog.New(h))
+	// Mask tokens, keys, emails and message content unless payload tracing is enabled
+   	slog.SetDefault(slog.New(logging.NewRedactingHandler(h, tracePayloads)))
    	switch strings.ToLower(logLevel) {
    	case "debug":
 		programLevel.Set(slog.LevelDebug)
@@ -194,8 +497,320 @@ func init() {
 
 }
 
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// parseChannelTypes validates the conversation types of a block, defaulting to public channels
+func parseChannelTypes(block string, types []string) []string {
+	if len(types) == 0 {
+		return []string{constants.PublicChannelType}
+	}
+	var result []string
+	for _, t := range types {
+		t = strings.ToLower(strings.TrimSpace(t))
+		switch t {
+		case constants.PublicChannelType, constants.PrivateChannelType, constants.MpimType, constants.ImType:
+		default:
+			log.Fatalf("Error: Please provide allowed types for %s (public_channel, private_channel, mpim, im): %v", block, t)
+		}
+		if !containsString(result, t) {
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+func parseCollectorSettings(workspace string, name string, attrs *LogsAttributes, defaults CollectorSettings) CollectorSettings {
+	if attrs == nil {
+		return defaults
+	}
+	settings := CollectorSettings{Enabled: attrs.Enabled}
+	if settings.Enabled {
+		interval, err := parseDuration(attrs.PollingInterval)
+		if err != nil && workspace == "" {
+			log.Fatalf("Error: %v, Please provide allowed pollingInterval for %s", err, name)
+		} else if err != nil {
+			log.Fatalf("Error: %v, Please provide allowed pollingInterval for %s in workspace %s", err, name, workspace)
+		}
+		settings.PollingInterval = interval
+	}
+	return settings
+}
+
+// parseCredential resolves a workspace credential from a secret reference or an environment variable
+func parseCredential(workspace string, ref string, envName string) *secrets.Secret {
+	var secret *secrets.Secret
+	var err error
+	if ref != "" {
+		secret, err = secrets.Parse(ref)
+	} else if envName != "" {
+		secret, err = secrets.FromEnv(envName)
+	}
+	if err != nil {
+		log.Fatalf("Error reading credentials of workspace %s: %v", workspace, err)
+	}
+	return secret
+}
+
+// unsafeFileNameChars are replaced in the file names derived from workspace names
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// parseWorkspaces returns the configured workspaces. Without a workspaces list a single
+// "default" workspace is collected with SLACK_ACCESS_TOKEN and the top level collector blocks.
+func parseWorkspaces(config Config) []Workspace {
+	defaults := map[string]CollectorSettings{
+		constants.ConversationLogsCollector: {Enabled: fetchConversationLogs, PollingInterval: conversationLogsPollingInterval},
+		constants.ChannelDetailsCollector:   {Enabled: fetchChannelDetails, PollingInterval: channelDetailsPollingInterval},
+		constants.UserLogsCollector:         {Enabled: fetchUserLogs, PollingInterval: userLogsPollingInterval},
+		constants.AccessLogsCollector:       {Enabled: fetchAccessLogs, PollingInterval: accessLogsPollingInterval},
+		constants.AuditLogsCollector:        {Enabled: fetchAuditLogs, PollingInterval: auditLogsPollingInterval},
+		constants.IntegrationLogsCollector:  parseCollectorSettings("", constants.IntegrationLogsCollector, &config.IntegrationLogs, CollectorSettings{}),
+		constants.ChannelMembershipCollector: parseCollectorSettings("", constants.ChannelMembershipCollector, &config.ChannelMembership.LogsAttributes, CollectorSettings{}),
+		constants.FileLogsCollector:         parseCollectorSettings("", constants.FileLogsCollector, &config.FileLogs, CollectorSettings{}),
+		constants.UserGroupsCollector:       parseCollectorSettings("", constants.UserGroupsCollector, &config.UserGroups, CollectorSettings{}),
+	}
+	for name, attrs := range config.Grid.collectors() {
+		defaults[name] = parseCollectorSettings("", name, attrs, CollectorSettings{})
+	}
+	if len(config.Workspaces) == 0 {
+		return []Workspace{{
+			Name:           "default",
+			Token:          parseCredential("default", "", "SLACK_ACCESS_TOKEN"),
+			RefreshToken:   parseCredential("default", "", "SLACK_REFRESH_TOKEN"),
+			ClientId:       parseCredential("default", "", "SLACK_CLIENT_ID"),
+			ClientSecret:   parseCredential("default", "", "SLACK_CLIENT_SECRET"),
+			TokenStorePath: tokenStorePath,
+			Collectors:     defaults,
+		}}
+	}
+
+	var result []Workspace
+	names := make(map[string]bool)
+	storePaths := make(map[string]bool) // default token stores, derived from the names
+	for _, wc := range config.Workspaces {
+		if wc.Name == "" || wc.TokenEnv == "" && wc.Token == "" && wc.RefreshTokenEnv == "" && wc.RefreshToken == "" {
+			log.Fatalf("Error: Please provide name and token (or tokenEnv) for every entry of workspaces")
+		}
+		if names[wc.Name] {
+			log.Fatalf("Error: Duplicate workspace name %s", wc.Name)
+		}
+		names[wc.Name] = true
+		ws := Workspace{
+			Name:            wc.Name,
+			Token:           parseCredential(wc.Name, wc.Token, wc.TokenEnv),
+			RefreshToken:    parseCredential(wc.Name, wc.RefreshToken, wc.RefreshTokenEnv),
+			ClientId:        parseCredential(wc.Name, wc.ClientId, wc.ClientIdEnv),
+			ClientSecret:    parseCredential(wc.Name, wc.ClientSecret, wc.ClientSecretEnv),
+			TokenStorePath:  wc.TokenStorePath,
+			Attributes:      wc.Attributes,
+			Collectors: map[string]CollectorSettings{
+				constants.ConversationLogsCollector: parseCollectorSettings(wc.Name, constants.ConversationLogsCollector, wc.ConversationLogs, defaults[constants.ConversationLogsCollector]),
+				constants.ChannelDetailsCollector:   parseCollectorSettings(wc.Name, constants.ChannelDetailsCollector, wc.ChannelDetails, defaults[constants.ChannelDetailsCollector]),
+				constants.UserLogsCollector:         parseCollectorSettings(wc.Name, constants.UserLogsCollector, wc.UserLogs, defaults[constants.UserLogsCollector]),
+				constants.AccessLogsCollector:       parseCollectorSettings(wc.Name, constants.AccessLogsCollector, wc.AccessLogs, defaults[constants.AccessLogsCollector]),
+				constants.AuditLogsCollector:        parseCollectorSettings(wc.Name, constants.AuditLogsCollector, wc.AuditLogs, defaults[constants.AuditLogsCollector]),
+				constants.IntegrationLogsCollector:  parseCollectorSettings(wc.Name, constants.IntegrationLogsCollector, wc.IntegrationLogs, defaults[constants.IntegrationLogsCollector]),
+				constants.ChannelMembershipCollector: parseCollectorSettings(wc.Name, constants.ChannelMembershipCollector, wc.ChannelMembership, defaults[constants.ChannelMembershipCollector]),
+				constants.FileLogsCollector:         parseCollectorSettings(wc.Name, constants.FileLogsCollector, wc.FileLogs, defaults[constants.FileLogsCollector]),
+				constants.UserGroupsCollector:       parseCollectorSettings(wc.Name, constants.UserGroupsCollector, wc.UserGroups, defaults[constants.UserGroupsCollector]),
+			},
+		}
+		// Grid blocks left out of the workspace's grid block inherit the top level ones
+		for name, attrs := range wc.Grid.collectors() {
+			ws.Collectors[name] = parseCollectorSettings(wc.Name, name, attrs, defaults[name])
+		}
+		for name, settings := range defaults {
+			if _, ok := ws.Collectors[name]; !ok {
+				ws.Collectors[name] = settings
+			}
+		}
+		if ws.TokenStorePath == "" {
+			// Workspace names may contain / or .., they must not lead out of the state directory
+			ws.TokenStorePath = fmt.Sprintf("slackTokens-%s.json", unsafeFileNameChars.ReplaceAllString(wc.Name, "_"))
+			if storePaths[ws.TokenStorePath] {
+				log.Fatalf("Error: Workspace %s has the same token store as another workspace, please set its tokenStorePath", wc.Name)
+			}
+			storePaths[ws.TokenStorePath] = true
+		}
+		result = append(result, ws)
+	}
+	return result
+}
+
+// parseEvents parses the events block, its flushInterval also applies to events received over Socket Mode
+func parseEvents(ec EventsConfig, socketModeEnabled bool) Events {
+	result := Events{Enabled: ec.Enabled, ListenAddress: ec.ListenAddress, Path: ec.Path, FlushInterval: 10 * time.Second}
+	var err error
+	if ec.FlushInterval != "" && (result.Enabled || socketModeEnabled) {
+		result.FlushInterval, err = parseDuration(ec.FlushInterval)
+		if err != nil {
+			log.Fatalf("Error: %v, Please provide allowed flushInterval for events", err)
+		}
+	}
+	if !result.Enabled {
+		return result
+	}
+	if result.ListenAddress == "" {
+		result.ListenAddress = ":3000"
+	}
+	if result.Path == "" {
+		result.Path = "/slack/events"
+	}
+	if ec.SigningSecret != "" {
+		result.SigningSecret, err = secrets.Parse(ec.SigningSecret)
+	} else {
+		result.SigningSecret, err = secrets.FromEnv("SLACK_SIGNING_SECRET")
+	}
+	if err != nil {
+		log.Fatalf("Error reading the signing secret for events: %v", err)
+	}
+	if result.SigningSecret == nil {
+		log.Fatalln("****  Please set SLACK_SIGNING_SECRET to receive Slack events. *****")
+	}
+	return result
+}
+
+func parseSocketMode(sc SocketModeConfig) SocketMode {
+	result := SocketMode{Enabled: sc.Enabled, ConnectionsOpenURL: sc.ConnectionsOpenURL}
+	if !result.Enabled {
+		return result
+	}
+	if result.ConnectionsOpenURL == "" {
+		result.ConnectionsOpenURL = constants.SlackConnectionsOpenAPIURL
+	}
+	var err error
+	if sc.AppToken != "" {
+		result.AppToken, err = secrets.Parse(sc.AppToken)
+	} else {
+		result.AppToken, err = secrets.FromEnv("SLACK_APP_TOKEN")
+	}
+	if err != nil {
+		log.Fatalf("Error reading the app-level token for Socket Mode: %v", err)
+	}
+	if result.AppToken == nil {
+		log.Fatalln("****  Please set SLACK_APP_TOKEN (xapp-...) to use Socket Mode. *****")
+	}
+	return result
+}
+
+// GetSocketMode returns the Socket Mode configuration
+func GetSocketMode() SocketMode {
+	return socketMode
+}
+
+// GetEnrichment returns the enrichment configuration
+func GetEnrichment() EnrichmentConfig {
+	return enrichment
+}
+
+// GetRendering returns the rendering configuration
+func GetRendering() RenderingConfig {
+	return rendering
+}
+
+// GetProjectionRules returns the field projection and drop rules per logtype
+func GetProjectionRules() map[string]projection.Rules {
+	return projectionRules
+}
+
+// GetRedaction returns the redaction configuration and the salt of hashed values, empty without a salt
+func GetRedaction() (redaction.Config, string) {
+	if redactionSalt == nil {
+		return redactionConfig, ""
+	}
+	return redactionConfig, redactionSalt.Value()
+}
+
+// GetEvents returns the Events API receiver configuration
+func GetEvents() Events {
+	return events
+}
+
+// GetWorkspaces returns the workspaces to collect logs from
+func GetWorkspaces() []Workspace {
+	return workspaces
+}
+
+// GetConversationLogsFilter returns the rules selecting the channels whose conversations are collected
+func GetConversationLogsFilter() *channelfilter.Filter {
+	return conversationLogsFilter
+}
+
+// GetReconcileWindow returns the window conversation logs re-fetch to find edits and deletions, 0 when disabled
+func GetReconcileWindow() time.Duration {
+	return reconcileWindow
+}
+
+// GetAutoJoin returns whether conversation logs join the selected public channels the token is not a member of
+func GetAutoJoin() bool {
+	return autoJoin
+}
+
+// GetThreadWindow returns the window of threads whose new replies conversation logs collect
+func GetThreadWindow() time.Duration {
+	return threadWindow
+}
+
+// GetChannelMembershipFilter returns the rules selecting the channels whose members are collected
+func GetChannelMembershipFilter() *channelfilter.Filter {
+	return channelMembershipFilter
+}
+
+// GetSessionPolicy returns the review settings of the Grid sessions collector
+func GetSessionPolicy() SessionPolicy {
+	return sessionPolicy
+}
+
+// GetChannelTypes returns the conversation types channel discovery lists with conversations.list
+func GetChannelTypes() []string {
+	return channelTypes
+}
+
+// GetConversationTypes returns the conversation types whose messages are collected
+func GetConversationTypes() []string {
+	return conversationTypes
+}
+
+// GetMembershipTypes returns the conversation types whose channel memberships are collected
+func GetMembershipTypes() []string {
+	return membershipTypes
+}
+
+// GetExcludeArchived reports whether channel discovery leaves out archived channels
+func GetExcludeArchived() bool {
+	return excludeArchived
+}
+
+// GetStateDirectory returns the directory for collector checkpoints, empty to keep them in memory
+func GetStateDirectory() string {
+	return stateDirectory
+}
+
+// GetRateLimitPerMinute returns the Slack API request budget shared by all workspaces, 0 means unlimited
+func GetRateLimitPerMinute() int {
+	return rateLimitPerMinute
+}
+
+// Enabled reports whether a collector is enabled for the workspace
+func (w Workspace) Enabled(name string) bool {
+	return w.Collectors[name].Enabled
+}
+
+// DisableCollector turns off a collector for the workspace, e.g. when its token is missing required scopes
+func (w Workspace) DisableCollector(name string) {
+	settings := w.Collectors[name]
+	settings.Enabled = false
+	w.Collectors[name] = settings
+}
+
 func GetNRApiKey() string {
-	return nrAccount
+	return nrAccount.Value()
 }
 
 func GetNRLogEndpoint() string {
@@ -249,3 +864,18 @@ func GetConversationLogsPollingInterval() time.Duration {
 func GetChannelDetailsPollingInterval() time.Duration {
 	return channelDetailsPollingInterval
 }
+
+// GetTokenStorePath returns the file where rotated Slack tokens are persisted
+func GetTokenStorePath() string {
+	return tokenStorePath
+}
+
+
+func GetScopePreflight() string {
+	return scopePreflight
+}
+
+// GetCommand returns the optional command passed as the first program argument
+func GetCommand() string {
+	return command
+}
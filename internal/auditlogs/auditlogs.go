@@ -6,7 +6,6 @@ import (
 	"time"
 	"strconv"
 
-	"slackLogs/internal/args"
 	"slackLogs/internal/common"
 	"slackLogs/internal/logclient"
 	c"slackLogs/internal/constants"
@@ -37,18 +36,28 @@ var otherAuditLog = &AuditLogEntity{Entity: c.OtherEntity, LogType: c.OtherAudit
 
 type auditLogsHandler struct {
         Client *logclient.LogClient
+	PollingInterval time.Duration
 }
 
-func NewAuditLogsHandler(client *logclient.LogClient) *auditLogsHandler {
-	return &auditLogsHandler{Client: client}
+func NewAuditLogsHandler(client *logclient.LogClient, pollingInterval time.Duration) *auditLogsHandler {
+	return &auditLogsHandler{Client: client, PollingInterval: pollingInterval}
 }
 
 type entity struct {
 	Type     string `json:"type"`
 	User     user    `json:"user"` 
+	Channel  *channel `json:"channel,omitempty"` // Set for channel entities
 	Random   map[string]interface{} `json:"-"`
 }
 
+type channel struct {
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	Privacy     string `json:"privacy"`
+	IsShared    bool   `json:"is_shared"`
+	IsOrgShared bool   `json:"is_org_shared"`
+}
+
 type user struct {
 	Id    string `json:"id"`
 	Name  string `json:"name"`
@@ -115,17 +124,17 @@ func transformAuditLogs(auditLogs []entry, ah *auditLogsHandler) error {
 		if errJson != nil {
 			return errJson
 		}
-		lm := logclient.Logs{
-			Timestamp: ts,
-			Message:   string(data),
-		}
-		ah.processLogType(l.Entity.Type, lm, len(data))
+		ah.processLogType(l.Entity.Type, ts, data)
 	}
 	return nil
 }
 
-func (ah *auditLogsHandler) processAuditLog(al *AuditLogEntity, lm logclient.Logs, size int) {
-        al.LogSize = al.LogSize + size
+func (ah *auditLogsHandler) processAuditLog(al *AuditLogEntity, ts int64, data []byte) {
+	lm, keep := ah.Client.NewLogs(al.LogType, ts, data)
+	if !keep {
+		return
+	}
+        al.LogSize = al.LogSize + len(lm.Message)
         al.Logs = append(al.Logs, lm)
         al.LogCount = al.LogCount + 1
 	ah.FlushLogs(al)
@@ -153,28 +162,27 @@ func (ah *auditLogsHandler) ResetLogs() {
 	slog.Debug("Reset audit logs: exit")
 }
 
-func (ah *auditLogsHandler) processLogType(entity string, data logclient.Logs, size int) {
+func (ah *auditLogsHandler) processLogType(entity string, ts int64, data []byte) {
 	switch entity {
 	case c.AppEntity:
-		ah.processAuditLog(appAuditLog, data, size)
+		ah.processAuditLog(appAuditLog, ts, data)
 	case c.ChannelEntity:
-		ah.processAuditLog(channelAuditLog, data, size)
+		ah.processAuditLog(channelAuditLog, ts, data)
 	case c.UserEntity:
-		ah.processAuditLog(userAuditLog, data, size)
+		ah.processAuditLog(userAuditLog, ts, data)
 	case c.FileEntity:
-		ah.processAuditLog(fileAuditLog, data, size)
+		ah.processAuditLog(fileAuditLog, ts, data)
 	case c.WorkspaceEntity:
-		ah.processAuditLog(workspaceAuditLog, data, size)
+		ah.processAuditLog(workspaceAuditLog, ts, data)
 	default:
-		ah.processAuditLog(otherAuditLog, data, size)
+		ah.processAuditLog(otherAuditLog, ts, data)
 	}
 }
 
 // Returns latest and oldest timestamp to collect audit logs
-func getTimeRange() (int64, int64){
+func getTimeRange(flushInterval time.Duration) (int64, int64){
         currentTime := time.Now()
         lastFetched := currentTime.Unix()
-	flushInterval := args.GetAuditLogsPollingInterval()
         slog.Info("Collecting audit logs", "for last(in minutes)", flushInterval.Minutes())
         lastBeforeFetched := currentTime.Add(-(flushInterval)).Unix()
 	return lastBeforeFetched, lastFetched
@@ -183,7 +191,7 @@ func getTimeRange() (int64, int64){
 func (al *auditLogsHandler) Collect(token string, teamId string, teamName string) error {
 	nextCursor := ""
 	slackToken = token
-	oldest, latest := getTimeRange()
+	oldest, latest := getTimeRange(al.PollingInterval)
 	
 	for {
 		c := common.NewSlackClient(c.SlackAuditLogsAPIURL, token, nextCursor)
@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"slackLogs/internal/common"
+	"slackLogs/internal/constants"
+)
+
+// RequiredScopes lists the OAuth scopes a token needs for each collector
+var RequiredScopes = map[string][]string{
+	constants.UserLogsCollector:          {"users:read", "admin"}, // admin for team.billableInfo
+	constants.ChannelDetailsCollector:    {"channels:read"},
+	constants.AccessLogsCollector:        {"admin"},
+	constants.AuditLogsCollector:         {"auditlogs:read"},
+	constants.ConversationLogsCollector:  {"channels:read", "channels:history"},
+	constants.IntegrationLogsCollector:   {"admin"},
+	constants.ChannelMembershipCollector: {"channels:read"},
+	constants.FileLogsCollector:          {"files:read", "users:read", "channels:read"},
+	constants.UserGroupsCollector:        {"usergroups:read"},
+	constants.GridTeamsCollector:         {"admin.teams:read"},
+	constants.GridUsersCollector:         {"admin.users:read"},
+	constants.GridConversationsCollector: {"admin.conversations:read"},
+	constants.GridAppsCollector:          {"admin.apps:read"},
+	constants.GridSessionsCollector:      {"admin.users:read", "admin"}, // admin for team.accessLogs
+}
+
+// Read and history scopes of each conversation type
+var channelTypeScopes = map[string][2]string{
+	constants.PublicChannelType:  {"channels:read", "channels:history"},
+	constants.PrivateChannelType: {"groups:read", "groups:history"},
+	constants.MpimType:           {"mpim:read", "mpim:history"},
+	constants.ImType:             {"im:read", "im:history"},
+}
+
+// RequireChannelTypes sets the scopes of channel discovery, conversation logs and channel memberships
+// for the configured conversation types
+func RequireChannelTypes(discoveryTypes []string, conversationTypes []string, membershipTypes []string) {
+	var discoveryScopes, conversationScopes, membershipScopes []string
+	for _, t := range discoveryTypes {
+		discoveryScopes = append(discoveryScopes, channelTypeScopes[t][0])
+	}
+	// conversations.members needs the read scope of the channel's type
+	for _, t := range membershipTypes {
+		membershipScopes = append(membershipScopes, channelTypeScopes[t][0])
+	}
+	for _, t := range conversationTypes {
+		conversationScopes = append(conversationScopes, channelTypeScopes[t][0], channelTypeScopes[t][1])
+	}
+	RequiredScopes[constants.ChannelDetailsCollector] = discoveryScopes
+	RequiredScopes[constants.ConversationLogsCollector] = conversationScopes
+	RequiredScopes[constants.ChannelMembershipCollector] = membershipScopes
+}
+
+// RequireChannelJoin adds the scope conversation logs need to join public channels
+func RequireChannelJoin() {
+	RequiredScopes[constants.ConversationLogsCollector] = append(RequiredScopes[constants.ConversationLogsCollector], "channels:join")
+}
+
+// authTestResponse contains slack API successful response
+// https://api.slack.com/methods/auth.test#examples
+type authTestResponse struct {
+	Ok                  bool   `json:"ok"`
+	URL                 string `json:"url"`
+	Team                string `json:"team"`
+	User                string `json:"user"`
+	TeamID              string `json:"team_id"`
+	UserID              string `json:"user_id"`
+	BotID               string `json:"bot_id"`
+	EnterpriseID        string `json:"enterprise_id"`
+	IsEnterpriseInstall bool   `json:"is_enterprise_install"`
+	ReqError            string `json:"error"`
+}
+
+// Info describes the identity behind a token and the scopes granted to it
+type Info struct {
+	Team                string
+	TeamID              string
+	User                string
+	UserID              string
+	BotID               string
+	EnterpriseID        string
+	IsEnterpriseInstall bool
+	Scopes              []string
+	ScopesKnown         bool // false when Slack did not return the x-oauth-scopes header
+}
+
+// ScopeReport is the result of comparing granted scopes with the collectors' requirements
+type ScopeReport struct {
+	Info    Info
+	Missing map[string][]string // collector name -> scopes not granted to the token
+}
+
+func GetAuthInfo(slackToken string) (Info, error) {
+	slackClient := common.NewSlackClient(constants.SlackAuthTestAPIURL, slackToken, "")
+	var responseData authTestResponse
+	errSlack := slackClient.SendRequest(common.WaitAndRetry, &responseData)
+	if errSlack != nil {
+		return Info{}, errSlack
+	}
+	if !responseData.Ok {
+		return Info{}, fmt.Errorf("Slack API error %v", responseData.ReqError)
+	}
+	info := Info{
+		Team:                responseData.Team,
+		TeamID:              responseData.TeamID,
+		User:                responseData.User,
+		UserID:              responseData.UserID,
+		BotID:               responseData.BotID,
+		EnterpriseID:        responseData.EnterpriseID,
+		IsEnterpriseInstall: responseData.IsEnterpriseInstall,
+	}
+	if header, ok := slackClient.ResponseHeader["X-Oauth-Scopes"]; ok && len(header) > 0 {
+		info.Scopes = parseScopes(header[0])
+		info.ScopesKnown = true
+	}
+	return info, nil
+}
+
+func parseScopes(header string) []string {
+	var scopes []string
+	for _, s := range strings.Split(header, ",") {
+		s = strings.TrimSpace(s)
+		if s != "" {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+// MissingScopes returns the required scopes that are not granted, per collector.
+// Collectors with all of their scopes granted are not part of the result.
+func MissingScopes(granted []string, collectors []string) map[string][]string {
+	grantedSet := make(map[string]bool, len(granted))
+	for _, s := range granted {
+		grantedSet[s] = true
+	}
+	missing := make(map[string][]string)
+	for _, collector := range collectors {
+		for _, scope := range RequiredScopes[collector] {
+			if !grantedSet[scope] {
+				missing[collector] = append(missing[collector], scope)
+			}
+		}
+	}
+	return missing
+}
+
+// CheckScopes calls auth.test with the token and reports which of the given collectors will fail
+func CheckScopes(slackToken string, collectors []string) (ScopeReport, error) {
+	info, err := GetAuthInfo(slackToken)
+	if err != nil {
+		return ScopeReport{}, err
+	}
+	report := ScopeReport{Info: info, Missing: map[string][]string{}}
+	if info.ScopesKnown {
+		report.Missing = MissingScopes(info.Scopes, collectors)
+	}
+	return report, nil
+}
+
+// FailingCollectors returns the collectors with missing scopes in a stable order
+func (r ScopeReport) FailingCollectors() []string {
+	var names []string
+	for name := range r.Missing {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
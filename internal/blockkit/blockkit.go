@@ -0,0 +1,280 @@
+package blockkit
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"slackLogs/internal/directory"
+)
+
+// Renderer converts the Block Kit blocks and mrkdwn text of messages into plain text, stored in text_plain
+type Renderer struct {
+	// Resolves user and channel mentions to names, mentions keep their labels or ids when nil
+	Directory *directory.Directory
+	// Removes the raw blocks from records which got a text_plain
+	DropBlocks bool
+}
+
+func NewRenderer(d *directory.Directory, dropBlocks bool) *Renderer {
+	return &Renderer{Directory: d, DropBlocks: dropBlocks}
+}
+
+func (r *Renderer) Process(logtype string, record map[string]interface{}) bool {
+	text, _ := record["text"].(string)
+	blocks, _ := record["blocks"].([]interface{})
+	if text == "" && len(blocks) == 0 {
+		return true
+	}
+	plain := r.RenderBlocks(blocks)
+	if plain == "" {
+		plain = r.RenderMrkdwn(text)
+	}
+	record["text_plain"] = plain
+	if r.DropBlocks {
+		delete(record, "blocks")
+	}
+	return true
+}
+
+func asMap(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	return m
+}
+
+func asList(v interface{}) []interface{} {
+	l, _ := v.([]interface{})
+	return l
+}
+
+func asString(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+func asInt(v interface{}) int {
+	switch n := v.(type) {
+	case float64:
+		return int(n)
+	case fmt.Stringer:
+		i, _ := strconv.Atoi(n.String())
+		return i
+	}
+	return 0
+}
+
+// RenderBlocks renders the blocks of a message, one line or paragraph per block
+func (r *Renderer) RenderBlocks(blocks []interface{}) string {
+	var parts []string
+	for _, b := range blocks {
+		if s := strings.TrimSpace(r.renderBlock(asMap(b))); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+func (r *Renderer) renderBlock(block map[string]interface{}) string {
+	switch asString(block["type"]) {
+	case "rich_text":
+		var parts []string
+		for _, e := range asList(block["elements"]) {
+			parts = append(parts, r.renderRichText(asMap(e)))
+		}
+		return strings.Join(parts, "\n")
+	case "section":
+		parts := []string{r.renderTextObject(asMap(block["text"]))}
+		for _, f := range asList(block["fields"]) {
+			parts = append(parts, r.renderTextObject(asMap(f)))
+		}
+		return strings.TrimSpace(strings.Join(parts, "\n"))
+	case "header":
+		return r.renderTextObject(asMap(block["text"]))
+	case "context":
+		var parts []string
+		for _, e := range asList(block["elements"]) {
+			element := asMap(e)
+			if asString(element["type"]) == "image" {
+				parts = append(parts, asString(element["alt_text"]))
+			} else {
+				parts = append(parts, r.renderTextObject(element))
+			}
+		}
+		return strings.Join(parts, " ")
+	case "image":
+		if title := r.renderTextObject(asMap(block["title"])); title != "" {
+			return title
+		}
+		return asString(block["alt_text"])
+	}
+	// Dividers, actions and inputs have no text
+	return ""
+}
+
+// renderTextObject renders a plain_text or mrkdwn composition object
+func (r *Renderer) renderTextObject(object map[string]interface{}) string {
+	text := asString(object["text"])
+	if asString(object["type"]) == "mrkdwn" {
+		return r.RenderMrkdwn(text)
+	}
+	return text
+}
+
+// renderRichText renders a rich_text_section, rich_text_list, rich_text_quote or rich_text_preformatted
+func (r *Renderer) renderRichText(element map[string]interface{}) string {
+	switch asString(element["type"]) {
+	case "rich_text_list":
+		indent := strings.Repeat("  ", asInt(element["indent"]))
+		ordered := asString(element["style"]) == "ordered"
+		var lines []string
+		for i, item := range asList(element["elements"]) {
+			marker := "- "
+			if ordered {
+				marker = strconv.Itoa(i+1) + ". "
+			}
+			lines = append(lines, indent+marker+r.renderRichText(asMap(item)))
+		}
+		return strings.Join(lines, "\n")
+	case "rich_text_quote":
+		lines := strings.Split(r.renderInline(asList(element["elements"])), "\n")
+		for i := range lines {
+			lines[i] = "> " + lines[i]
+		}
+		return strings.Join(lines, "\n")
+	default:
+		// rich_text_section and rich_text_preformatted
+		return r.renderInline(asList(element["elements"]))
+	}
+}
+
+// renderInline renders the text, link, emoji and mention elements of a rich text section
+func (r *Renderer) renderInline(elements []interface{}) string {
+	var b strings.Builder
+	for _, e := range elements {
+		element := asMap(e)
+		switch asString(element["type"]) {
+		case "text":
+			b.WriteString(asString(element["text"]))
+		case "link":
+			url, label := asString(element["url"]), asString(element["text"])
+			b.WriteString(link(url, label))
+		case "emoji":
+			b.WriteString(emoji(asString(element["name"]), asString(element["unicode"])))
+		case "user":
+			b.WriteString(r.userMention(asString(element["user_id"]), ""))
+		case "channel":
+			b.WriteString(r.channelMention(asString(element["channel_id"]), ""))
+		case "usergroup":
+			b.WriteString("@" + asString(element["usergroup_id"]))
+		case "broadcast":
+			b.WriteString("@" + asString(element["range"]))
+		case "date":
+			b.WriteString(asString(element["fallback"]))
+		}
+	}
+	return b.String()
+}
+
+func link(url string, label string) string {
+	if label == "" || label == url {
+		return url
+	}
+	return label + " (" + url + ")"
+}
+
+// emoji renders an emoji from its code points, e.g. 1f44d-1f3fb, and as :name: when they are unknown
+func emoji(name string, unicode string) string {
+	if unicode == "" {
+		return ":" + name + ":"
+	}
+	var b strings.Builder
+	for _, hex := range strings.Split(unicode, "-") {
+		code, err := strconv.ParseInt(hex, 16, 32)
+		if err != nil {
+			return ":" + name + ":"
+		}
+		b.WriteRune(rune(code))
+	}
+	return b.String()
+}
+
+func (r *Renderer) userMention(id string, label string) string {
+	if r.Directory != nil {
+		if u, ok := r.Directory.User(id); ok {
+			return "@" + u.Name
+		}
+	}
+	if label != "" {
+		return "@" + strings.TrimPrefix(label, "@")
+	}
+	return "@" + id
+}
+
+func (r *Renderer) channelMention(id string, label string) string {
+	if r.Directory != nil {
+		if name, ok := r.Directory.ChannelName(id); ok {
+			return "#" + name
+		}
+	}
+	if label != "" {
+		return "#" + label
+	}
+	return "#" + id
+}
+
+var (
+	// <@U123|label>, <#C123|name>, <!here>, <!subteam^S123|@team>, <https://example.com|label>
+	mrkdwnControl = regexp.MustCompile(`<([@#!]?)([^<>|]*)(?:\|([^<>]*))?>`)
+	codeFence     = regexp.MustCompile("```\\n?")
+	// Formatting markers count at word boundaries only, e.g. not in snake_case
+	mrkdwnStyles = []*regexp.Regexp{
+		regexp.MustCompile(`(^|[^\w*])\*([^*\n]+)\*($|[^\w*])`),
+		regexp.MustCompile(`(^|[^\w_])_([^_\n]+)_($|[^\w_])`),
+		regexp.MustCompile(`(^|[^\w~])~([^~\n]+)~($|[^\w~])`),
+		regexp.MustCompile("(^|[^\\w`])`([^`\\n]+)`($|[^\\w`])"),
+	}
+	controlPlaceholder = regexp.MustCompile("\x00([0-9]+)\x00")
+	mrkdwnEscapes      = strings.NewReplacer("&lt;", "<", "&gt;", ">", "&amp;", "&")
+)
+
+// RenderMrkdwn converts Slack mrkdwn into plain text: mentions and links are resolved, formatting
+// markers are removed and escapes are decoded
+func (r *Renderer) RenderMrkdwn(text string) string {
+	text = codeFence.ReplaceAllString(text, "")
+	// Control sequences are set aside while markers are removed, so URLs and labels keep e.g. their _ and *
+	var controls []string
+	text = mrkdwnControl.ReplaceAllStringFunc(text, func(control string) string {
+		controls = append(controls, r.renderControl(control))
+		return "\x00" + strconv.Itoa(len(controls)-1) + "\x00"
+	})
+	for _, style := range mrkdwnStyles {
+		// Twice, as adjacent words share the boundary between them, e.g. *a* *b*
+		text = style.ReplaceAllString(text, "${1}${2}${3}")
+		text = style.ReplaceAllString(text, "${1}${2}${3}")
+	}
+	text = controlPlaceholder.ReplaceAllStringFunc(text, func(placeholder string) string {
+		i, _ := strconv.Atoi(strings.Trim(placeholder, "\x00"))
+		return controls[i]
+	})
+	return mrkdwnEscapes.Replace(text)
+}
+
+// renderControl resolves a control sequence, e.g. a mention or a link
+func (r *Renderer) renderControl(control string) string {
+	m := mrkdwnControl.FindStringSubmatch(control)
+	sigil, target, label := m[1], m[2], m[3]
+	switch sigil {
+	case "@":
+		return r.userMention(target, label)
+	case "#":
+		return r.channelMention(target, label)
+	case "!":
+		if label != "" {
+			// <!subteam^S123|@team>, <!date^1392734382^{date}|Feb 18th>
+			return label
+		}
+		return "@" + target
+	}
+	return link(target, label)
+}
@@ -0,0 +1,154 @@
+package blockkit
+
+import (
+	"encoding/json"
+	"testing"
+
+	"slackLogs/internal/channelregistry"
+	"slackLogs/internal/directory"
+	"slackLogs/internal/model"
+)
+
+func testRenderer() *Renderer {
+	channels := channelregistry.New()
+	channels.Publish("T1", []model.Channel{{ID: "C1", Name: "general", IsChannel: true}})
+	d := directory.New(channels)
+	d.SetUsers("T1", []directory.User{{ID: "U1", Name: "jane_doe"}})
+	return NewRenderer(d, false)
+}
+
+func TestRenderMrkdwn(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"plain", "hello world", "hello world"},
+		{"styles", "*bold* _italic_ ~strike~ `code`", "bold italic strike code"},
+		{"adjacent styles", "*a* *b*", "a b"},
+		{"snake case", "snake_case_name and 2*3*4", "snake_case_name and 2*3*4"},
+		{"code fence", "```\nfmt.Println()```", "fmt.Println()"},
+		{"user mention", "hi <@U1>", "hi @jane_doe"},
+		{"unknown user keeps label", "hi <@U2|bob>", "hi @bob"},
+		{"unknown user keeps id", "hi <@U2>", "hi @U2"},
+		{"channel mention", "see <#C1>", "see #general"},
+		{"unknown channel keeps label", "see <#C2|random>", "see #random"},
+		{"special mention", "<!here> and <!subteam^S1|@team>", "@here and @team"},
+		{"link", "<https://example.com>", "https://example.com"},
+		{"link with label", "<https://example.com|Example>", "Example (https://example.com)"},
+		{"link keeps underscores", "<https://host/a_b_c|x>", "x (https://host/a_b_c)"},
+		{"link keeps markers", "<https://host/_x_/*y*>", "https://host/_x_/*y*"},
+		{"styled link", "*<https://example.com|Example>*", "Example (https://example.com)"},
+		{"escapes", "a &lt;b&gt; &amp; c", "a <b> & c"},
+	}
+	r := testRenderer()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.RenderMrkdwn(tt.text); got != tt.want {
+				t.Errorf("RenderMrkdwn(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderBlocks(t *testing.T) {
+	tests := []struct {
+		name   string
+		blocks string
+		want   string
+	}{
+		{
+			"rich text section",
+			`[{"type":"rich_text","elements":[{"type":"rich_text_section","elements":[
+				{"type":"text","text":"hi "},{"type":"user","user_id":"U1"},{"type":"text","text":" in "},
+				{"type":"channel","channel_id":"C1"},{"type":"text","text":" "},{"type":"emoji","name":"thumbsup","unicode":"1f44d"},
+				{"type":"text","text":" "},{"type":"emoji","name":"custom"},{"type":"text","text":" "},
+				{"type":"link","url":"https://example.com","text":"Example"},{"type":"text","text":" "},
+				{"type":"broadcast","range":"here"}]}]}]`,
+			"hi @jane_doe in #general 👍 :custom: Example (https://example.com) @here",
+		},
+		{
+			"rich text lists",
+			`[{"type":"rich_text","elements":[
+				{"type":"rich_text_list","style":"bullet","elements":[
+					{"type":"rich_text_section","elements":[{"type":"text","text":"one"}]},
+					{"type":"rich_text_section","elements":[{"type":"text","text":"two"}]}]},
+				{"type":"rich_text_list","style":"ordered","indent":1,"elements":[
+					{"type":"rich_text_section","elements":[{"type":"text","text":"first"}]}]}]}]`,
+			"- one\n- two\n  1. first",
+		},
+		{
+			"rich text quote and preformatted",
+			`[{"type":"rich_text","elements":[
+				{"type":"rich_text_quote","elements":[{"type":"text","text":"a\nb"}]},
+				{"type":"rich_text_preformatted","elements":[{"type":"text","text":"x := 1"}]}]}]`,
+			"> a\n> b\nx := 1",
+		},
+		{
+			"section header and context",
+			`[{"type":"header","text":{"type":"plain_text","text":"Title"}},
+				{"type":"section","text":{"type":"mrkdwn","text":"*bold* <@U1>"},"fields":[{"type":"plain_text","text":"field"}]},
+				{"type":"divider"},
+				{"type":"context","elements":[{"type":"image","alt_text":"logo"},{"type":"mrkdwn","text":"_note_"}]}]`,
+			"Title\nbold @jane_doe\nfield\nlogo note",
+		},
+		{
+			"image",
+			`[{"type":"image","alt_text":"alt"},{"type":"image","alt_text":"alt","title":{"type":"plain_text","text":"title"}}]`,
+			"alt\ntitle",
+		},
+	}
+	r := testRenderer()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var blocks []interface{}
+			if err := json.Unmarshal([]byte(tt.blocks), &blocks); err != nil {
+				t.Fatal(err)
+			}
+			if got := r.RenderBlocks(blocks); got != tt.want {
+				t.Errorf("RenderBlocks() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcess(t *testing.T) {
+	tests := []struct {
+		name       string
+		record     string
+		dropBlocks bool
+		want       string
+		wantBlocks bool
+	}{
+		{"blocks win over text", `{"text":"*fallback*","blocks":[{"type":"header","text":{"type":"plain_text","text":"Title"}}]}`, false, "Title", true},
+		{"text without blocks", `{"text":"*fallback*"}`, false, "fallback", false},
+		{"text when blocks have none", `{"text":"*fallback*","blocks":[{"type":"divider"}]}`, false, "fallback", true},
+		{"drop blocks", `{"text":"x","blocks":[{"type":"header","text":{"type":"plain_text","text":"Title"}}]}`, true, "Title", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var record map[string]interface{}
+			if err := json.Unmarshal([]byte(tt.record), &record); err != nil {
+				t.Fatal(err)
+			}
+			r := testRenderer()
+			r.DropBlocks = tt.dropBlocks
+			if !r.Process("SlackMessages", record) {
+				t.Fatal("record was dropped")
+			}
+			if got := record["text_plain"]; got != tt.want {
+				t.Errorf("text_plain = %q, want %q", got, tt.want)
+			}
+			if _, ok := record["blocks"]; ok != tt.wantBlocks {
+				t.Errorf("blocks kept = %v, want %v", ok, tt.wantBlocks)
+			}
+		})
+	}
+	record := map[string]interface{}{"user": "U1"}
+	if !testRenderer().Process("SlackMessages", record) {
+		t.Fatal("record without text was dropped")
+	}
+	if _, ok := record["text_plain"]; ok {
+		t.Error("text_plain was set on a record without text")
+	}
+}
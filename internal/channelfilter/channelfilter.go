@@ -0,0 +1,214 @@
+package channelfilter
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"slackLogs/internal/constants"
+)
+
+// Allowed values of Rules.Archived and Rules.SlackConnect
+const (
+	Include = "include" // Channels with and without the property, the default
+	Exclude = "exclude" // Only channels without the property
+	Only    = "only"    // Only channels with the property
+)
+
+// Rules selects channels by channel ID, by a glob on the channel name, e.g. "incident-*",
+// or by a regular expression on the channel name written as "/regex/".
+// An empty include list selects every channel which is not excluded.
+type Rules struct {
+	Include      []string  `yaml:"include"`
+	Exclude      []string  `yaml:"exclude"`
+	MinMembers   int       `yaml:"minMembers"`
+	MaxMembers   int       `yaml:"maxMembers"`   // 0 means no limit
+	Archived     string    `yaml:"archived"`     // include, exclude or only
+	SlackConnect string    `yaml:"slackConnect"` // include, exclude or only
+	Types        TypeRules `yaml:"types"`
+}
+
+// TypeRules selects channels by conversation type: public_channel, private_channel, mpim or im.
+// An empty include list selects every type which is not excluded, a plain list is short for include.
+type TypeRules struct {
+	Include []string `yaml:"include"`
+	Exclude []string `yaml:"exclude"`
+}
+
+func (t *TypeRules) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.SequenceNode {
+		t.Exclude = nil
+		return value.Decode(&t.Include)
+	}
+	type plain TypeRules
+	return value.Decode((*plain)(t))
+}
+
+func containsType(types []string, channelType string) bool {
+	for _, t := range types {
+		if strings.EqualFold(strings.TrimSpace(t), channelType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether the conversation type is selected
+func (t TypeRules) Match(channelType string) bool {
+	if len(t.Include) > 0 && !containsType(t.Include, channelType) {
+		return false
+	}
+	return !containsType(t.Exclude, channelType)
+}
+
+// Channel holds the channel attributes rules are evaluated on
+type Channel struct {
+	ID          string
+	Name        string
+	NumMembers  int
+	IsArchived  bool
+	IsExtShared bool
+	Type        string // public_channel, private_channel, mpim or im
+}
+
+// Validate checks the regular expressions and the allowed values of the rules
+func (r Rules) Validate() error {
+	for _, p := range append(append([]string{}, r.Include...), r.Exclude...) {
+		if expr, ok := regexPattern(p); ok {
+			if _, err := regexp.Compile(expr); err != nil {
+				return fmt.Errorf("invalid channel name regex %s: %v", p, err)
+			}
+		}
+	}
+	for _, v := range []string{r.Archived, r.SlackConnect} {
+		switch strings.ToLower(v) {
+		case "", Include, Exclude, Only:
+		default:
+			return fmt.Errorf("invalid value %s, allowed are include, exclude and only", v)
+		}
+	}
+	for _, t := range append(append([]string{}, r.Types.Include...), r.Types.Exclude...) {
+		switch strings.ToLower(strings.TrimSpace(t)) {
+		case constants.PublicChannelType, constants.PrivateChannelType, constants.MpimType, constants.ImType:
+		default:
+			return fmt.Errorf("invalid channel type %s, allowed are public_channel, private_channel, mpim and im", t)
+		}
+	}
+	if r.MaxMembers > 0 && r.MinMembers > r.MaxMembers {
+		return fmt.Errorf("minMembers %d is greater than maxMembers %d", r.MinMembers, r.MaxMembers)
+	}
+	return nil
+}
+
+func regexPattern(pattern string) (string, bool) {
+	if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		return pattern[1 : len(pattern)-1], true
+	}
+	return "", false
+}
+
+// pattern is an include or exclude pattern with its name regex compiled
+type pattern struct {
+	value string
+	expr  *regexp.Regexp // Set for "/regex/" patterns
+}
+
+func compilePatterns(values []string) ([]pattern, error) {
+	patterns := make([]pattern, 0, len(values))
+	for _, v := range values {
+		p := pattern{value: v}
+		if expr, ok := regexPattern(v); ok {
+			compiled, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid channel name regex %s: %v", v, err)
+			}
+			p.expr = compiled
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+func (p pattern) match(id string, name string) bool {
+	if p.value == id {
+		return true
+	}
+	if p.expr != nil {
+		return p.expr.MatchString(name)
+	}
+	matched, err := path.Match(strings.TrimPrefix(p.value, "#"), name)
+	return err == nil && matched
+}
+
+func matchesAny(patterns []pattern, id string, name string) bool {
+	for _, p := range patterns {
+		if p.match(id, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesProperty(rule string, value bool) bool {
+	switch strings.ToLower(rule) {
+	case Exclude:
+		return !value
+	case Only:
+		return value
+	default:
+		return true
+	}
+}
+
+// Filter selects channels by rules whose name regexes are compiled once
+type Filter struct {
+	Rules
+	include []pattern
+	exclude []pattern
+}
+
+// New validates the rules and compiles their name regexes
+func New(r Rules) (*Filter, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	f := &Filter{Rules: r}
+	var err error
+	if f.include, err = compilePatterns(r.Include); err != nil {
+		return nil, err
+	}
+	if f.exclude, err = compilePatterns(r.Exclude); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Match reports whether the channel is selected by the include and exclude lists
+func (f *Filter) Match(id string, name string) bool {
+	if len(f.include) > 0 && !matchesAny(f.include, id, name) {
+		return false
+	}
+	return !matchesAny(f.exclude, id, name)
+}
+
+// reportsMembers reports whether channels of the type have a member count, IMs and MPIMs report 0
+func reportsMembers(channelType string) bool {
+	return channelType != constants.ImType && channelType != constants.MpimType
+}
+
+// MatchChannel reports whether the channel is selected by all rules
+func (f *Filter) MatchChannel(c Channel) bool {
+	if !f.Match(c.ID, c.Name) {
+		return false
+	}
+	if reportsMembers(c.Type) && (c.NumMembers < f.MinMembers || f.MaxMembers > 0 && c.NumMembers > f.MaxMembers) {
+		return false
+	}
+	if !f.Types.Match(c.Type) {
+		return false
+	}
+	return matchesProperty(f.Archived, c.IsArchived) && matchesProperty(f.SlackConnect, c.IsExtShared)
+}
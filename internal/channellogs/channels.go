@@ -5,12 +5,14 @@ import (
 	"log/slog"
 	"time"
 	"fmt"
+	"strconv"
+	"strings"
 
+	"slackLogs/internal/channelregistry"
 	"slackLogs/internal/common"
 	"slackLogs/internal/logclient"
 	"slackLogs/internal/model"
 	"slackLogs/internal/constants"
-	"slackLogs/internal/args"
 )
 
 var (
@@ -21,15 +23,20 @@ var (
 
 var logs = []logclient.Logs{}
 var slackToken string
-var channelsInfo = make(map[string]string)
-var isClosed = false
 
 type ChannelLogsHandler struct {
 	Client *logclient.LogClient
+	// When false, channels are only discovered for conversation logs and not exported
+	ExportLogs bool
+	// Conversation types to list, e.g. public_channel, private_channel, mpim, im
+	Types           []string
+	ExcludeArchived bool
+	// Receives the channel list of each team once it is complete
+	Registry *channelregistry.Registry
 }
 
-func NewChannelLogsHandler(client *logclient.LogClient) *ChannelLogsHandler {
-	return &ChannelLogsHandler{Client: client}
+func NewChannelLogsHandler(client *logclient.LogClient, registry *channelregistry.Registry, exportLogs bool, types []string, excludeArchived bool) *ChannelLogsHandler {
+	return &ChannelLogsHandler{Client: client, Registry: registry, ExportLogs: exportLogs, Types: types, ExcludeArchived: excludeArchived}
 }
 
 // ConversationsListResponse contains slack API successful response
@@ -43,10 +50,12 @@ type channelsListResponse struct {
 	ReqError string `json:"error"`
 }
 
-func getSlackChannelLogs(c *common.SlackClient, teamId string) (channelsListResponse, error) {
+func getSlackChannelLogs(c *common.SlackClient, teamId string, types []string, excludeArchived bool) (channelsListResponse, error) {
 	slackClient := common.NewSlackClient(c.SlackAPIURL, c.SlackToken, c.Cursor)
 	params := map[string]string{
                 "team_id": teamId,
+                "types": strings.Join(types, ","),
+                "exclude_archived": strconv.FormatBool(excludeArchived),
         }
 	var responseData channelsListResponse
 	errSlack := slackClient.SendRequest(common.WaitAndRetry, &responseData, params)
@@ -59,19 +68,20 @@ func getSlackChannelLogs(c *common.SlackClient, teamId string) (channelsListResp
 	return responseData, nil
 }
 
-func transformChannelLogs(channelLogs []model.Channel, teamName string) error {
+func transformChannelLogs(client *logclient.LogClient, channelLogs []model.Channel, teamName string) error {
 	ts := time.Now().Unix()
 	for _, l := range channelLogs {
 		l.TeamName = teamName
+		l.Type = l.ChannelType()
 		data, errJson := json.Marshal(l)
-		totalLogsSize = totalLogsSize + len(data)
 		if errJson != nil {
 			return errJson
 		}
-		lm := logclient.Logs{
-			Timestamp: ts,
-			Message:   string(data),
+		lm, keep := client.NewLogs(logtype, ts, data)
+		if !keep {
+			continue
 		}
+		totalLogsSize = totalLogsSize + len(lm.Message)
 		logCount = logCount + 1
 		logs = append(logs, lm)
 	}
@@ -79,11 +89,7 @@ func transformChannelLogs(channelLogs []model.Channel, teamName string) error {
 }
 
 func (cl *ChannelLogsHandler) ResetLogs() {
-	if (!args.GetChannelDetailsEnabled()) {
-		return
-	}
-
-	if len(logs) > 0 {
+	if len(logs) > 0 && cl.ExportLogs {
 		cl.Client.Flush(logtype, logs)
 	}
 	logs = []logclient.Logs{}
@@ -91,37 +97,23 @@ func (cl *ChannelLogsHandler) ResetLogs() {
 	logCount = 0
 }
 
-func updateChannelsInfo(channelsListCh chan<- map[string]string) {
-        channelsListCh <- channelsInfo
-}
-
-func closeChannelsInfo(channelsListCh chan<- map[string]string) {
-	close(channelsListCh)
-}
 
 func (cl *ChannelLogsHandler) Collect(token string, teamId string, teamName string) error {
-	slog.Info("Collecting channel deatils")
+	slog.Info("Collecting channel details", "team", teamName)
 	nextCursor := ""
 	logCount = 0
 	slackToken = token
-	slog.Info("Creating new chaneell")
-	isClosed = true
+	var channels []model.Channel
 	for {
-		var ChannelsListCh = make(chan map[string]string)
-		go RecvChannelsInfo(ChannelsListCh)
 		c := common.NewSlackClient(constants.SlackChannelAPIURL, slackToken, nextCursor)
 		// Get Channel logs
-		response, err := getSlackChannelLogs(c, teamId)
+		response, err := getSlackChannelLogs(c, teamId, cl.Types, cl.ExcludeArchived)
 		if err != nil {
 			return err
 		}
-		for _, l := range response.Channels {
-			channelsInfo[l.ID] = l.Name
-                }
-	   	ChannelsListCh <- channelsInfo
-		closeChannelsInfo(ChannelsListCh)
+		channels = append(channels, response.Channels...)
 		// Filter required fields and add timestamp to each log
-		err = transformChannelLogs(response.Channels, teamName)
+		err = transformChannelLogs(cl.Client, response.Channels, teamName)
 		if err != nil {
 			return err
 		}
@@ -132,37 +124,13 @@ func (cl *ChannelLogsHandler) Collect(token string, teamId string, teamName stri
 		next := response.ResponseMetaData.NextCursor
 		if next == "" {
 			slog.Debug("There is no next page, collected channels list")
-        		cl.ResetLogs()
 			break
 		}
 		nextCursor = next
 	}
+	// Only a complete channel list is handed to the collectors which work per channel
+	cl.Registry.Publish(teamId, channels)
 	// Flush rest of the logs
         cl.ResetLogs()
 	return nil
 }
-
-
-func RecvChannelsInfo(channelsListCh <-chan map[string]string) {
-        for {
-                slog.Info("Receiving the channels")
-                channels, ok := <- channelsListCh
-                if !ok {
-                        slog.Info("Received all the channels. Closed.")
-                        isClosed = true
-                        return
-                }
-                for key, value := range channels {
-                        channelsInfo[key] = value
-                }
-        }
-}
-
-func GetChannelsInfo() map[string]string {
-        return channelsInfo
-}
-
-func GetChannelStatus() bool {
-        slog.Info("GetChannelStatus", "isClosed", isClosed)
-        return isClosed
-}
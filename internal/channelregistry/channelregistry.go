@@ -0,0 +1,164 @@
+package channelregistry
+
+import (
+	"log/slog"
+	"sync"
+
+	"slackLogs/internal/channelfilter"
+	"slackLogs/internal/model"
+)
+
+// Registry holds the channels discovered by the ChannelDetails collector for each team. Every
+// completed discovery replaces the team's snapshot with a new version, collectors which work per
+// channel read the latest snapshot or subscribe to be notified of new versions.
+type Registry struct {
+	mux         sync.RWMutex
+	snapshots   map[string]*Snapshot              // team id -> latest snapshot
+	subscribers map[string]map[int]chan *Snapshot // team id -> subscription id -> channel
+	nextID      int
+}
+
+func New() *Registry {
+	return &Registry{snapshots: make(map[string]*Snapshot), subscribers: make(map[string]map[int]chan *Snapshot)}
+}
+
+// Snapshot is the complete channel list of a team at one version. It is never modified after it
+// is published, so it can be read from any goroutine.
+type Snapshot struct {
+	TeamID   string
+	Version  uint64
+	channels map[string]model.Channel
+	names    map[string]string
+}
+
+// Publish replaces the channels of a team and notifies its subscribers
+func (r *Registry) Publish(teamId string, channels []model.Channel) *Snapshot {
+	s := &Snapshot{TeamID: teamId, channels: make(map[string]model.Channel, len(channels)), names: make(map[string]string, len(channels))}
+	for _, c := range channels {
+		c.Type = c.ChannelType()
+		s.channels[c.ID] = c
+		name := c.Name
+		if name == "" {
+			// IMs have no name, use the other user's id
+			name = c.User
+		}
+		s.names[c.ID] = name
+	}
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if previous, ok := r.snapshots[teamId]; ok {
+		s.Version = previous.Version + 1
+	} else {
+		s.Version = 1
+	}
+	r.snapshots[teamId] = s
+	for _, ch := range r.subscribers[teamId] {
+		// Subscribers only need the latest version, replace a version they didn't receive yet
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- s
+	}
+	slog.Info("Published channels", "team", teamId, "version", s.Version, "channels", len(channels))
+	return s
+}
+
+// Latest returns the latest snapshot of a team, false when its channels were not discovered yet
+func (r *Registry) Latest(teamId string) (*Snapshot, bool) {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	s, ok := r.snapshots[teamId]
+	return s, ok
+}
+
+// ChannelName returns the name of a channel from the latest snapshots of all teams
+func (r *Registry) ChannelName(channelId string) (string, bool) {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	for _, s := range r.snapshots {
+		if name, ok := s.names[channelId]; ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// Subscribe returns a channel which receives the latest snapshot of a team, right away when one was
+// published already, and then each new version. A slow subscriber skips the versions it missed.
+// The returned function ends the subscription and closes the channel.
+func (r *Registry) Subscribe(teamId string) (<-chan *Snapshot, func()) {
+	ch := make(chan *Snapshot, 1)
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	id := r.nextID
+	r.nextID++
+	if r.subscribers[teamId] == nil {
+		r.subscribers[teamId] = make(map[int]chan *Snapshot)
+	}
+	r.subscribers[teamId][id] = ch
+	if s, ok := r.snapshots[teamId]; ok {
+		ch <- s
+	}
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			r.mux.Lock()
+			defer r.mux.Unlock()
+			delete(r.subscribers[teamId], id)
+			close(ch)
+		})
+	}
+}
+
+// Wait blocks until the channels of all given teams were discovered
+func (r *Registry) Wait(teamIds []string) {
+	for _, teamId := range teamIds {
+		ch, unsubscribe := r.Subscribe(teamId)
+		<-ch
+		unsubscribe()
+	}
+}
+
+// Names returns channel id -> channel name, IMs are named by the id of the other user
+func (s *Snapshot) Names() map[string]string {
+	names := make(map[string]string, len(s.names))
+	for id, name := range s.names {
+		names[id] = name
+	}
+	return names
+}
+
+// Len returns the number of channels
+func (s *Snapshot) Len() int {
+	return len(s.channels)
+}
+
+// Name returns the name of a channel, IMs are named by the id of the other user
+func (s *Snapshot) Name(channelId string) (string, bool) {
+	name, ok := s.names[channelId]
+	return name, ok
+}
+
+// Channel returns a channel with its type, privacy flags and member count
+func (s *Snapshot) Channel(channelId string) (model.Channel, bool) {
+	c, ok := s.channels[channelId]
+	return c, ok
+}
+
+// Type returns the conversation type of a channel, e.g. public_channel or im
+func (s *Snapshot) Type(channelId string) string {
+	return s.channels[channelId].Type
+}
+
+// IsExtShared reports whether a channel is shared with another organization (Slack Connect)
+func (s *Snapshot) IsExtShared(channelId string) bool {
+	return s.channels[channelId].IsExtShared
+}
+
+// FilterChannel returns the attributes channel filter rules are evaluated on
+func (s *Snapshot) FilterChannel(channelId string) channelfilter.Channel {
+	c := s.channels[channelId]
+	return channelfilter.Channel{ID: channelId, Name: s.names[channelId], NumMembers: c.NumMembers, IsArchived: c.IsArchived, IsExtShared: c.IsExtShared, Type: c.Type}
+}
@@ -0,0 +1,132 @@
+package channelregistry
+
+import (
+	"sync"
+	"testing"
+
+	"slackLogs/internal/model"
+)
+
+var testChannels = []model.Channel{
+	{ID: "C1", Name: "general", IsChannel: true},
+	{ID: "D1", User: "U1", IsIM: true},
+}
+
+func TestPublishIncreasesVersion(t *testing.T) {
+	r := New()
+	if _, ok := r.Latest("T1"); ok {
+		t.Fatal("expected no snapshot before the first publish")
+	}
+	for want := uint64(1); want <= 3; want++ {
+		s := r.Publish("T1", testChannels)
+		if s.Version != want {
+			t.Fatalf("version = %d, want %d", s.Version, want)
+		}
+		if latest, _ := r.Latest("T1"); latest != s {
+			t.Fatalf("latest is not the published snapshot of version %d", want)
+		}
+	}
+	// Versions are counted per team
+	if s := r.Publish("T2", nil); s.Version != 1 {
+		t.Fatalf("version of another team = %d, want 1", s.Version)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	s := New().Publish("T1", testChannels)
+	if name, _ := s.Name("D1"); name != "U1" {
+		t.Errorf("IM name = %q, want the id of the other user", name)
+	}
+	if s.Type("C1") != "public_channel" || s.Type("D1") != "im" {
+		t.Errorf("types = %s, %s", s.Type("C1"), s.Type("D1"))
+	}
+	if c := s.FilterChannel("C1"); c.ID != "C1" || c.Name != "general" {
+		t.Errorf("filter channel = %+v", c)
+	}
+	// Names is a copy, changing it doesn't change the snapshot
+	names := s.Names()
+	names["C1"] = "changed"
+	if name, _ := s.Name("C1"); name != "general" {
+		t.Errorf("snapshot changed through Names: %q", name)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	r := New()
+	first := r.Publish("T1", testChannels)
+	ch, unsubscribe := r.Subscribe("T1")
+	if got := <-ch; got != first {
+		t.Fatal("subscriber didn't receive the published snapshot right away")
+	}
+	// A subscriber which doesn't read skips to the latest version
+	r.Publish("T1", testChannels)
+	latest := r.Publish("T1", testChannels)
+	if got := <-ch; got != latest {
+		t.Fatalf("received version %d, want %d", got.Version, latest.Version)
+	}
+	unsubscribe()
+	unsubscribe()
+	if _, ok := <-ch; ok {
+		t.Fatal("channel is open after unsubscribe")
+	}
+	// Publishing after unsubscribe doesn't send on the closed channel
+	r.Publish("T1", testChannels)
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	const publishers, publishes = 4, 50
+	r := New()
+	waited := make(chan struct{})
+	go func() {
+		r.Wait([]string{"T1", "T2"})
+		close(waited)
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < publishers; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < publishes; j++ {
+				r.Publish("T1", testChannels)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			ch, unsubscribe := r.Subscribe("T1")
+			defer unsubscribe()
+			var last uint64
+			for j := 0; j < publishes; j++ {
+				if s, ok := r.Latest("T1"); ok {
+					s.Names()
+					s.FilterChannel("C1")
+				}
+				r.ChannelName("C1")
+				select {
+				case s := <-ch:
+					if s.Version <= last {
+						t.Errorf("received version %d after %d", s.Version, last)
+					}
+					last = s.Version
+					if name, _ := s.Name("D1"); name != "U1" || s.Type("D1") != "im" {
+						t.Errorf("snapshot of version %d has D1 %q of type %s", s.Version, name, s.Type("D1"))
+					}
+				default:
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	select {
+	case <-waited:
+		t.Fatal("Wait returned before all teams were published")
+	default:
+	}
+	r.Publish("T2", nil)
+	<-waited
+
+	if s, _ := r.Latest("T1"); s.Version != publishers*publishes {
+		t.Fatalf("version = %d, want %d", s.Version, publishers*publishes)
+	}
+}
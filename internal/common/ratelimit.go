@@ -0,0 +1,40 @@
+package common
+
+import (
+	"sync"
+	"time"
+)
+
+// Slack API requests of all workspaces and collectors share one request budget.
+var (
+	rateLimitMux    sync.Mutex
+	requestInterval time.Duration
+	nextRequest     time.Time
+)
+
+// SetRateLimit spaces Slack API requests to at most perMinute requests per minute, 0 disables the limit
+func SetRateLimit(perMinute int) {
+	rateLimitMux.Lock()
+	defer rateLimitMux.Unlock()
+	requestInterval = 0
+	if perMinute > 0 {
+		requestInterval = time.Minute / time.Duration(perMinute)
+	}
+}
+
+// waitForRateLimit blocks until the next request fits in the shared budget
+func waitForRateLimit() {
+	rateLimitMux.Lock()
+	if requestInterval == 0 {
+		rateLimitMux.Unlock()
+		return
+	}
+	now := time.Now()
+	if nextRequest.Before(now) {
+		nextRequest = now
+	}
+	wait := nextRequest.Sub(now)
+	nextRequest = nextRequest.Add(requestInterval)
+	rateLimitMux.Unlock()
+	time.Sleep(wait)
+}
@@ -10,6 +10,8 @@ import (
 	"net/url"
 	"strconv"
 	"time"
+
+	"slackLogs/internal/logging"
 )
 
 type CollectLogs interface {
@@ -22,6 +24,8 @@ type SlackClient struct {
 	SlackToken   string
 	DefaultLimit int
 	Cursor       string
+	tokenRefreshed bool // A request is retried at most once after refreshing an expired token
+	ResponseHeader http.Header // Headers of the last successful response, e.g. x-oauth-scopes
 }
 
 var (
@@ -76,6 +80,7 @@ func (c *SlackClient) SendRequest(retryCallback RetryCallback, responseData inte
 	encodedParams := params.Encode()
 	slackUrl := fmt.Sprintf("%s?%s", c.SlackAPIURL, encodedParams)
 	slog.Debug("API request", "slackUrl", slackUrl)
+	waitForRateLimit()
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 
@@ -85,6 +90,11 @@ func (c *SlackClient) SendRequest(retryCallback RetryCallback, responseData inte
 		return err
 	}
 
+	// Rotating tokens: always send the latest token issued for this one
+	tokenSource := lookupTokenSource(c.SlackToken)
+	if tokenSource != nil {
+		c.SlackToken = tokenSource.Token()
+	}
 	req.Header.Set("Authorization", "Bearer "+c.SlackToken)
 
 	response, errClient := HttpClient.Do(req)
@@ -100,15 +110,29 @@ func (c *SlackClient) SendRequest(retryCallback RetryCallback, responseData inte
 		}
 		return c.SendRequest(retryCallback, responseData)
 	}
+	defer response.Body.Close()
+	body, errResponse := ioutil.ReadAll(response.Body)
+	if errResponse == nil && tokenSource != nil && !c.tokenRefreshed && isTokenExpired(response, body) {
+		slog.Info("Slack token expired, refreshing and retrying the request")
+		if errRefresh := tokenSource.Refresh(c.SlackToken); errRefresh != nil {
+			return errRefresh
+		}
+		c.tokenRefreshed = true
+		if len(optionalParams) > 0 {
+			return c.SendRequest(retryCallback, responseData, optionalParams[0])
+		}
+		return c.SendRequest(retryCallback, responseData)
+	}
 	if response.StatusCode == 401 {
 		slog.Debug("Insufficient permissions to access", "slackUrl", slackUrl)
 		return fmt.Errorf("HTTP error %v", response.StatusCode)
 	} else if response.StatusCode >= 300 {
 		return fmt.Errorf("HTTP error %v", response.StatusCode)
 	}
-	defer response.Body.Close()
-	body, errResponse := ioutil.ReadAll(response.Body)
-	slog.Debug("Collected logs in slackAPI", "body", string(body))
+	c.ResponseHeader = response.Header
+	if logging.TracePayloads() {
+		slog.Debug("Collected logs in slackAPI", "body", string(body))
+	}
 	if errResponse == nil {
 		if err = json.Unmarshal(body, &responseData); err != nil {
 			return err
@@ -0,0 +1,250 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"slackLogs/internal/secrets"
+)
+
+const (
+	oauthV2AccessAPIURL = "https://slack.com/api/oauth.v2.access"
+	// Refresh a rotating token this long before it expires
+	tokenRefreshMargin = 5 * time.Minute
+)
+
+// TokenSource holds a rotating Slack access token together with the refresh token used to renew it.
+// https://api.slack.com/authentication/rotation
+type TokenSource struct {
+	mux           sync.Mutex
+	accessToken   string
+	replacedToken string // Access token before the last refresh, collections started with it may still run
+	refreshToken  string
+	clientID      string
+	clientSecret  *secrets.Secret // Re-read on every refresh, so a rotated secret file is picked up
+	expiresAt     time.Time
+	storePath     string
+}
+
+// storedTokens is the token pair persisted in the token store file
+type storedTokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresAt    int64  `json:"expires_at"`
+}
+
+// oauthV2AccessResponse contains slack API successful response
+// https://api.slack.com/methods/oauth.v2.access#examples
+type oauthV2AccessResponse struct {
+	Ok           bool   `json:"ok"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	TokenType    string `json:"token_type"`
+	AuthedUser   struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int64  `json:"expires_in"`
+	} `json:"authed_user"`
+	ReqError string `json:"error"`
+}
+
+// The current and the replaced access token of a TokenSource map to it, so clients holding the
+// older token transparently use the current one. Tokens replaced before are removed.
+var (
+	tokenSourcesMux sync.Mutex
+	tokenSources    = make(map[string]*TokenSource)
+)
+
+// NewTokenSource creates a rotating token source. When storePath holds a previously
+// persisted token pair it takes precedence over the given tokens, because Slack
+// invalidates a refresh token once it has been used.
+func NewTokenSource(accessToken, refreshToken, clientID string, clientSecret *secrets.Secret, storePath string) (*TokenSource, error) {
+	if refreshToken == "" || clientID == "" || clientSecret.Value() == "" {
+		return nil, errors.New("token rotation requires a refresh token, client id and client secret")
+	}
+	ts := &TokenSource{
+		accessToken:  accessToken,
+		refreshToken: refreshToken,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		storePath:    storePath,
+	}
+	if err := ts.load(); err != nil {
+		return nil, err
+	}
+	if ts.accessToken == "" {
+		if err := ts.Refresh(""); err != nil {
+			return nil, err
+		}
+	}
+	registerTokenSource(ts.accessToken, ts)
+	return ts, nil
+}
+
+func registerTokenSource(token string, ts *TokenSource) {
+	tokenSourcesMux.Lock()
+	tokenSources[token] = ts
+	tokenSourcesMux.Unlock()
+}
+
+func unregisterTokenSource(token string, ts *TokenSource) {
+	tokenSourcesMux.Lock()
+	if tokenSources[token] == ts {
+		delete(tokenSources, token)
+	}
+	tokenSourcesMux.Unlock()
+}
+
+func lookupTokenSource(token string) *TokenSource {
+	tokenSourcesMux.Lock()
+	defer tokenSourcesMux.Unlock()
+	return tokenSources[token]
+}
+
+func (ts *TokenSource) load() error {
+	if ts.storePath == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(ts.storePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading token store %s: %v", ts.storePath, err)
+	}
+	var stored storedTokens
+	if err = json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("error parsing token store %s: %v", ts.storePath, err)
+	}
+	if stored.RefreshToken != "" {
+		ts.accessToken = stored.AccessToken
+		ts.refreshToken = stored.RefreshToken
+		ts.expiresAt = time.Time{}
+		if stored.ExpiresAt > 0 {
+			ts.expiresAt = time.Unix(stored.ExpiresAt, 0)
+		}
+		slog.Info("Loaded rotated Slack token from token store", "path", ts.storePath, "expiresAt", ts.expiresAt)
+	}
+	return nil
+}
+
+func (ts *TokenSource) save() error {
+	if ts.storePath == "" {
+		return nil
+	}
+	data, err := json.Marshal(storedTokens{AccessToken: ts.accessToken, RefreshToken: ts.refreshToken, ExpiresAt: ts.expiresAt.Unix()})
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first so a crash never leaves a truncated store behind
+	tmp := ts.storePath + ".tmp"
+	if err = ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, ts.storePath)
+}
+
+// Token returns the current access token, refreshing it first when it is about to expire
+func (ts *TokenSource) Token() string {
+	ts.mux.Lock()
+	token := ts.accessToken
+	expiring := !ts.expiresAt.IsZero() && time.Until(ts.expiresAt) < tokenRefreshMargin
+	ts.mux.Unlock()
+	if expiring {
+		if err := ts.Refresh(token); err != nil {
+			slog.Error("Not able to refresh the Slack token before it expires", "error", err)
+			return token
+		}
+		ts.mux.Lock()
+		token = ts.accessToken
+		ts.mux.Unlock()
+	}
+	return token
+}
+
+// Refresh exchanges the refresh token for a new token pair with oauth.v2.access.
+// expired is the access token the caller found to be expired; when another caller
+// already replaced it the refresh is skipped.
+func (ts *TokenSource) Refresh(expired string) error {
+	ts.mux.Lock()
+	defer ts.mux.Unlock()
+	if expired != ts.accessToken {
+		return nil
+	}
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", ts.refreshToken)
+	form.Set("client_id", ts.clientID)
+	form.Set("client_secret", ts.clientSecret.Value())
+
+	response, err := HttpClient.PostForm(oauthV2AccessAPIURL, form)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("HTTP error %v", response.StatusCode)
+	}
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	var responseData oauthV2AccessResponse
+	if err = json.Unmarshal(body, &responseData); err != nil {
+		return err
+	}
+	if !responseData.Ok {
+		return fmt.Errorf("Slack API error %v", responseData.ReqError)
+	}
+	accessToken, refreshToken, expiresIn := responseData.AccessToken, responseData.RefreshToken, responseData.ExpiresIn
+	if accessToken == "" {
+		// User tokens are returned in authed_user
+		accessToken, refreshToken, expiresIn = responseData.AuthedUser.AccessToken, responseData.AuthedUser.RefreshToken, responseData.AuthedUser.ExpiresIn
+	}
+	if accessToken == "" {
+		return errors.New("oauth.v2.access did not return an access token")
+	}
+	if ts.replacedToken != "" && ts.replacedToken != accessToken {
+		unregisterTokenSource(ts.replacedToken, ts)
+	}
+	ts.replacedToken = ts.accessToken
+	ts.accessToken = accessToken
+	if refreshToken != "" {
+		ts.refreshToken = refreshToken
+	}
+	ts.expiresAt = time.Time{}
+	if expiresIn > 0 {
+		ts.expiresAt = time.Now().Add(time.Duration(expiresIn) * time.Second)
+	}
+	registerTokenSource(accessToken, ts)
+	slog.Info("Refreshed rotating Slack token", "expiresAt", ts.expiresAt)
+	if err = ts.save(); err != nil {
+		slog.Error("Not able to persist the refreshed Slack token", "path", ts.storePath, "error", err)
+	}
+	return nil
+}
+
+// isTokenExpired checks a Slack API response body for the token_expired error
+func isTokenExpired(response *http.Response, body []byte) bool {
+	if response.StatusCode == http.StatusUnauthorized && strings.Contains(string(body), "token_expired") {
+		return true
+	}
+	var apiError struct {
+		Ok    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(body, &apiError) != nil {
+		return false
+	}
+	return !apiError.Ok && apiError.Error == "token_expired"
+}
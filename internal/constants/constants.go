@@ -6,11 +6,31 @@ const (
         SlackTeamInfoAPIURL = "https://slack.com/api/team.info"
         SlackBillingInfoAPIURL = "https://slack.com/api/team.billableInfo"
         SlackaccessAPIURL = "https://slack.com/api/team.accessLogs"
+	SlackIntegrationLogsAPIURL = "https://slack.com/api/team.integrationLogs"
 	SlackChannelAPIURL  = "https://slack.com/api/conversations.list"
 	SlackChannelHistoryAPIURL  = "https://slack.com/api/conversations.history"
 	SlackChannelRepliesAPIURL  = "https://slack.com/api/conversations.replies"
+	SlackChannelMembersAPIURL  = "https://slack.com/api/conversations.members"
+	SlackConversationsJoinAPIURL  = "https://slack.com/api/conversations.join"
+	SlackFilesListAPIURL  = "https://slack.com/api/files.list"
+	SlackUserInfoAPIURL  = "https://slack.com/api/users.info"
+	SlackUserGroupsAPIURL  = "https://slack.com/api/usergroups.list"
+	SlackAdminTeamsListAPIURL  = "https://slack.com/api/admin.teams.list"
+	SlackAdminUsersListAPIURL  = "https://slack.com/api/admin.users.list"
+	SlackAdminConversationsSearchAPIURL  = "https://slack.com/api/admin.conversations.search"
+	SlackAdminAppsApprovedAPIURL  = "https://slack.com/api/admin.apps.approved.list"
+	SlackAdminAppsRestrictedAPIURL  = "https://slack.com/api/admin.apps.restricted.list"
+	SlackAdminSessionListAPIURL  = "https://slack.com/api/admin.users.session.list"
+	SlackAdminSessionSettingsAPIURL  = "https://slack.com/api/admin.users.session.getSettings"
 	SlackTeamsListAPIURL  = "https://slack.com/api/auth.teams.list"
+	SlackAuthTestAPIURL  = "https://slack.com/api/auth.test"
+	SlackConnectionsOpenAPIURL  = "https://slack.com/api/apps.connections.open"
 	SlackAuditLogsAPIURL  = "https://api.slack.com/audit/v1/logs"
+	// Conversation types of conversations.list
+	PublicChannelType = "public_channel"
+	PrivateChannelType = "private_channel"
+	MpimType = "mpim"
+	ImType = "im"
 	UserEntity = "user"
 	ChannelEntity = "channel"
 	FileEntity  = "file"
@@ -23,4 +43,20 @@ const (
         FileAuditLogType   = "FileAuditLog"
         AppAuditLogType   = "AppAuditLog"
         OtherAuditLogsType   = "OtherAuditLogs"
+	// Collector names used in logs, scope checks and configuration
+	UserLogsCollector         = "UserLogs"
+	ChannelDetailsCollector   = "ChannelDetails"
+	AccessLogsCollector       = "AccessLogs"
+	AuditLogsCollector        = "AuditLogs"
+	ConversationLogsCollector = "ConversationLogs"
+	IntegrationLogsCollector  = "IntegrationLogs"
+	ChannelMembershipCollector = "ChannelMembership"
+	FileLogsCollector         = "FileLogs"
+	UserGroupsCollector       = "UserGroups"
+	// Enterprise Grid collectors, they need an org-level token
+	GridTeamsCollector         = "GridTeams"
+	GridUsersCollector         = "GridUsers"
+	GridConversationsCollector = "GridConversations"
+	GridAppsCollector          = "GridApps"
+	GridSessionsCollector      = "GridSessions"
 )
@@ -7,12 +7,13 @@ import (
 	"fmt"
 	"strconv"
 
+	"slackLogs/internal/channelfilter"
 	"slackLogs/internal/common"
-	"slackLogs/internal/args"
 	"slackLogs/internal/logclient"
 	"slackLogs/internal/model"
 	"slackLogs/internal/constants"
-	"slackLogs/internal/channellogs"
+	"slackLogs/internal/channelregistry"
+	"slackLogs/internal/state"
 )
 
 var (
@@ -21,18 +22,83 @@ var (
 	logCount      = 0 // This variable helps to track number of logs exported in each request
 )
 
-var logs = []logclient.Logs{}
-var slackToken string
-var teamName string
-var channelsInfo = make(map[string]string)
+// Messages of each conversation type are exported with their own logtype, so they can be retained and accessed separately
+var logtypes = map[string]string{
+	constants.PublicChannelType:  logtype,
+	constants.PrivateChannelType: "PrivateConversationLog",
+	constants.MpimType:           "GroupDMConversationLog",
+	constants.ImType:             "DirectMessageLog",
+}
+
+var logs = make(map[string][]logclient.Logs) // logtype -> logs
 //var ChannelsListCh := make(chan map[string]string)
 
 type ConversationLogsHandler struct {
 	Client *logclient.LogClient
+	// Channels discovered by the ChannelDetails collector
+	Registry *channelregistry.Registry
+	PollingInterval time.Duration
+	// Conversation types to collect, e.g. public_channel, private_channel, mpim, im
+	Types []string
+	// Selects the channels to collect, before any conversations.history call
+	Filter *channelfilter.Filter
+	// Edits and deletions are looked for in this window, reconciliation is disabled when 0
+	ReconcileWindow time.Duration
+	hashes          *state.Store // team id/channel id/message ts -> content record
+	// New replies are collected for threads started within this window
+	ThreadWindow time.Duration
+	threads      *state.Store // team id/channel id/thread ts -> ts of the last collected reply
+	// Public channels the token is not a member of are joined, otherwise they are skipped
+	AutoJoin bool
+	joined   *state.Store // team id/channel id -> unix time the channel was joined
 }
 
-func NewConversationLogsHandler(client *logclient.LogClient) *ConversationLogsHandler {
-	return &ConversationLogsHandler{Client: client}
+func NewConversationLogsHandler(client *logclient.LogClient, registry *channelregistry.Registry, pollingInterval time.Duration, types []string, filter *channelfilter.Filter, reconcileWindow time.Duration, threadWindow time.Duration, autoJoin bool) (*ConversationLogsHandler, error) {
+	cl := &ConversationLogsHandler{Client: client, Registry: registry, PollingInterval: pollingInterval, Types: types, Filter: filter, ReconcileWindow: reconcileWindow, ThreadWindow: threadWindow, AutoJoin: autoJoin}
+	threads, err := state.Open("conversationThreads")
+	if err != nil {
+		return nil, err
+	}
+	cl.threads = threads
+	joined, err := state.Open("conversationJoins")
+	if err != nil {
+		return nil, err
+	}
+	cl.joined = joined
+	if reconcileWindow > 0 {
+		hashes, err := state.Open("conversationHashes")
+		if err != nil {
+			return nil, err
+		}
+		cl.hashes = hashes
+	}
+	return cl, nil
+}
+
+func typeOfChannel(channels *channelregistry.Snapshot, channelId string) string {
+	if t := channels.Type(channelId); t != "" {
+		return t
+	}
+	return constants.PublicChannelType
+}
+
+// collectedByOtherTeam reports whether a channel shared between teams of a Grid org is collected with
+// the team which created it, so its messages are collected once and attributed to that team
+func (cl *ConversationLogsHandler) collectedByOtherTeam(teamId string, c model.Channel) bool {
+	if c.ContextTeamID == "" || c.ContextTeamID == teamId {
+		return false
+	}
+	_, ok := cl.Registry.Latest(c.ContextTeamID)
+	return ok
+}
+
+func (cl *ConversationLogsHandler) collectsType(channelType string) bool {
+	for _, t := range cl.Types {
+		if t == channelType {
+			return true
+		}
+	}
+	return false
 }
 
 // conversationsListResponse contains slack API successful response
@@ -52,7 +118,7 @@ type conversationsListResponse struct {
 // https://api.slack.com/methods/conversations.replies#examples
 type conversationsReplyResponse struct {
 	Ok               bool         `json:"ok"`
-	RepliesList        []model.ConversationReply `json:"messages"`
+	RepliesList        []model.Conversation `json:"messages"`
 	ResponseMetaData struct {
 		NextCursor string `json:"next_cursor"`
 	} `json:"response_metadata"`
@@ -69,16 +135,6 @@ type TeamInfoResponse struct {
 	Random           map[string]interface{} `json:"-"`
 }
 
-type replyParams struct {
-	TimeStamp int64
-	Channel   string
-	Token     string
-	Latest    int64
-	Oldest    int64
-	Client    *common.SlackClient
-}
-
-
 func getSlackConversationLogs(c *common.SlackClient, channelId string, oldest int64, latest int64) (conversationsListResponse, error) {
 	params := map[string]string{
                 "channel": channelId,
@@ -98,105 +154,114 @@ func getSlackConversationLogs(c *common.SlackClient, channelId string, oldest in
 	return responseData, nil
 }
 
-func transformConversationLogs(conversationLogs []model.Conversation, channelID string, channelName string) error {
+func transformConversationLogs(client *logclient.LogClient, conversationLogs []model.Conversation, teamName string, channelID string, channelName string, channelType string) error {
 	ts := time.Now().Unix()
 	for _, l := range conversationLogs {
-		if l.ReplyCount >= 1 {
-			repliesList, err := getReplies(l.TimeStamp, channelID)
-			if err != nil {
-				return fmt.Errorf("Error while getting replies for channel %s -  %v", channelID, err)
-			}
-			l.RepliesList = repliesList
-		}
 		l.TeamName = teamName
 		l.ChannelID = channelID
 		l.ChannelName = channelName
+		l.ChannelType = channelType
 		data, errJson := json.Marshal(l)
-		totalLogsSize = totalLogsSize + len(data)
 		if errJson != nil {
 			return errJson
 		}
-		lm := logclient.Logs{
-			Timestamp: ts,
-			Message:   string(data),
+		lm, keep := client.NewLogs(logtypes[channelType], ts, data)
+		if !keep {
+			continue
 		}
+		totalLogsSize = totalLogsSize + len(lm.Message)
 		logCount = logCount + 1
-		logs = append(logs, lm)
+		logs[logtypes[channelType]] = append(logs[logtypes[channelType]], lm)
 	}
 	return nil
 }
 
 func (cl *ConversationLogsHandler) ResetLogs() {
-	if len(logs) > 0 {
-		cl.Client.Flush(logtype, logs)
+	for lt, typeLogs := range logs {
+		if len(typeLogs) > 0 {
+			cl.Client.Flush(lt, typeLogs)
+		}
 	}
-	logs = []logclient.Logs{}
+	logs = make(map[string][]logclient.Logs)
 	totalLogsSize = 0
         logCount = 0
 }
 
-func getReplies(timeStamp string, channelId string) ([]model.ConversationReply, error) {
-	nextCursor := ""
-	var repliesList []model.ConversationReply
-	ts := timeStamp
-	for {
-		slackClient := common.NewSlackClient(constants.SlackChannelRepliesAPIURL, slackToken, nextCursor)
-		params := map[string]string {
-                          "channel": channelId,
-                           "limit": strconv.Itoa(200),
-                           "ts": ts,
-                }
-		var responseData conversationsReplyResponse
-        	errSlack := slackClient.SendRequest(common.WaitAndRetry, &responseData, params)
-        	if errSlack != nil {
-                	return repliesList, errSlack
-		}
-        	if !responseData.Ok {
-                	return repliesList, fmt.Errorf("Slack API error %v", responseData.ReqError)
-        	}
-		if len(repliesList) > 0 {
-			 ts = repliesList[len(repliesList)-1].TimeStamp
-		}
-		for  _, reply := range responseData.RepliesList {
-			repliesList = append(repliesList, reply)
-		}
-                if !responseData.HasMoreData {
-                        break
-                }
-		next := responseData.ResponseMetaData.NextCursor
-		if next == "" {
-                        slog.Debug("There is no next page, collected conversations")
-                        break
-		}
-	}
-	return repliesList,nil
-}
-
 func (cl *ConversationLogsHandler) Collect(token string, tId string, tName string) error {
-	flushInterval := args.GetConversationLogsPollingInterval()
+	flushInterval := cl.PollingInterval
 	nextCursor := ""
 	logCount = 0
-	channelsInfo = channellogs.GetChannelsInfo()
-	teamName = tName
-	slackToken = token
+	channels, ok := cl.Registry.Latest(tId)
+	if !ok {
+		slog.Warn("Channels of the team are not discovered yet, skipping conversation logs", "team", tName)
+		return nil
+	}
 	currentTime := time.Now()
         latestTimeStamp := currentTime.Unix()
 	// If flushInterval is 24 hours , it will fetch last 24hours conversations in the channel
         oldestTimeStamp := currentTime.Add(-(flushInterval)).Unix()
 	slog.Info("Collecting conversational logs", "for last(in minutes)", flushInterval.Minutes())
-	for  channelId, channelName := range channelsInfo {
+	selected := make(map[string]string)
+	collectedByOtherTeam := 0
+	for channelId, channelName := range channels.Names() {
+		if !cl.collectsType(typeOfChannel(channels, channelId)) || !cl.Filter.MatchChannel(channels.FilterChannel(channelId)) {
+			continue
+		}
+		if c, _ := channels.Channel(channelId); cl.collectedByOtherTeam(tId, c) {
+			collectedByOtherTeam++
+			continue
+		}
+		selected[channelId] = channelName
+	}
+	slog.Info("Selected channels for conversation logs", "team", tName, "selected", len(selected), "channels", channels.Len(),
+		"collectedByOtherTeam", collectedByOtherTeam, "version", channels.Version)
+	threadWindow := cl.ThreadWindow
+	if threadWindow < flushInterval {
+		threadWindow = flushInterval
+	}
+	skipped := 0
+nextChannel:
+	for  channelId, channelName := range selected {
+		channelType := typeOfChannel(channels, channelId)
+		var parents, fetched []model.Conversation
+		joinAttempted := false
 		for {
 			c := common.NewSlackClient(constants.SlackChannelHistoryAPIURL, token, nextCursor)
 			// Get Conversation logs
 			response, err := getSlackConversationLogs(c, channelId, oldestTimeStamp, latestTimeStamp)
+			if err != nil && response.ReqError == notInChannel && nextCursor == "" {
+				canRead := false
+				if !joinAttempted {
+					joinAttempted = true
+					if canRead, err = cl.joinForHistory(token, tId, channelId, channelName, channelType); err != nil {
+						return err
+					}
+				}
+				if canRead {
+					continue
+				}
+				skipped++
+				continue nextChannel
+			}
 			if err != nil {
 				return err
 			}
 			// Filter required fields and add timestamp to each log
-			err = transformConversationLogs(response.ConversationsList, channelId, channelName)
+			err = transformConversationLogs(cl.Client, response.ConversationsList, tName, channelId, channelName, channelType)
 			if err != nil {
 				return err
 			}
+			if cl.hashes != nil {
+				if err = rememberMessages(cl.hashes, tId, channelId, response.ConversationsList); err != nil {
+					return err
+				}
+				fetched = append(fetched, response.ConversationsList...)
+			}
+			for _, m := range response.ConversationsList {
+				if m.ReplyCount > 0 {
+					parents = append(parents, m)
+				}
+			}
 			// Check total collected logs size and maximum allowed logs size in a single request
 			if totalLogsSize >= constants.MaxAllowed {
 				cl.ResetLogs()
@@ -209,8 +274,35 @@ func (cl *ConversationLogsHandler) Collect(token string, tId string, tName strin
 			}
 			nextCursor = next
 		}
+		if cl.hashes != nil {
+			window := cl.ReconcileWindow
+			if window < flushInterval {
+				window = flushInterval
+			}
+			olderParents, err := cl.reconcile(token, tId, tName, channelId, channelName, channelType, fetched, currentTime.Add(-window).Unix(), oldestTimeStamp, latestTimeStamp)
+			if err != nil {
+				return fmt.Errorf("Error while reconciling channel %s - %v", channelId, err)
+			}
+			parents = append(parents, olderParents...)
+		}
+		// Parents fetched again, e.g. in the reconcile window, may have new replies of threads collected earlier
+		threadsOldest := currentTime.Add(-threadWindow).Unix()
+		if err := cl.collectThreadReplies(token, tId, tName, channelId, channelName, channelType, parents, threadsOldest); err != nil {
+			return err
+		}
+	}
+	if skipped > 0 {
+		slog.Warn("Skipped channels which the token is not a member of", "team", tName, "skipped", skipped, "selected", len(selected))
 	}
 	// Flush rest of the logs
 	cl.ResetLogs()
-	return nil
+	if err := cl.joined.Save(); err != nil {
+		return err
+	}
+	if cl.hashes != nil {
+		if err := cl.hashes.Save(); err != nil {
+			return err
+		}
+	}
+	return cl.threads.Save()
 }
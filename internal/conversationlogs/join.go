@@ -0,0 +1,53 @@
+package conversationlogs
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"slackLogs/internal/common"
+	"slackLogs/internal/constants"
+)
+
+// conversations.history returns not_in_channel for bot tokens on channels the bot is not a member of.
+// Public channels can be joined with conversations.join, other channels are skipped.
+
+const notInChannel = "not_in_channel"
+
+// conversationsJoinResponse contains slack API successful response
+// https://api.slack.com/methods/conversations.join#examples
+type conversationsJoinResponse struct {
+	Ok       bool   `json:"ok"`
+	ReqError string `json:"error"`
+}
+
+func joinChannel(token string, channelId string) error {
+	slackClient := common.NewSlackClient(constants.SlackConversationsJoinAPIURL, token, "")
+	var responseData conversationsJoinResponse
+	errSlack := slackClient.SendRequest(common.WaitAndRetry, &responseData, map[string]string{"channel": channelId})
+	if errSlack != nil {
+		return errSlack
+	}
+	if !responseData.Ok {
+		return fmt.Errorf("Slack API error %v", responseData.ReqError)
+	}
+	return nil
+}
+
+// joinForHistory joins a public channel the token is not a member of, when auto-join is enabled.
+// It reports whether the history of the channel can be read now, channels which can't are skipped.
+func (cl *ConversationLogsHandler) joinForHistory(token string, teamId string, channelId string, channelName string, channelType string) (bool, error) {
+	if !cl.AutoJoin || channelType != constants.PublicChannelType {
+		slog.Warn("Skipping channel, not a member of it", "channel", channelName, "id", channelId, "type", channelType, "autoJoin", cl.AutoJoin)
+		return false, nil
+	}
+	if err := joinChannel(token, channelId); err != nil {
+		slog.Warn("Skipping channel, not able to join it", "channel", channelName, "id", channelId, "error", err)
+		return false, nil
+	}
+	slog.Info("Joined channel to read its history", "channel", channelName, "id", channelId)
+	if err := cl.joined.Set(teamId+"/"+channelId, time.Now().Unix()); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,201 @@
+package conversationlogs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"slackLogs/internal/common"
+	"slackLogs/internal/constants"
+	"slackLogs/internal/logclient"
+	"slackLogs/internal/model"
+	"slackLogs/internal/state"
+)
+
+// Polling conversations.history can't observe edits and deletions of messages which were already
+// collected. Reconciliation compares the messages of a recent window with a hash store, reusing the
+// messages the polling fetched and fetching only the older part of the window again.
+
+var changeLogtype = "ConversationChangeLog"
+
+// contentRecord is what the hash store keeps of a collected message
+type contentRecord struct {
+	Hash     string `json:"hash"`
+	Text     string `json:"text"`     // Last collected text
+	Original string `json:"original"` // Text when the message was collected first, retained for legal hold
+	User     string `json:"user"`
+}
+
+func contentHash(m model.Conversation) string {
+	h := sha256.New()
+	h.Write([]byte(m.Text))
+	for _, f := range m.Files {
+		h.Write([]byte{0})
+		h.Write([]byte(f.ID))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func messageKey(teamId string, channelId string, ts string) string {
+	return teamId + "/" + channelId + "/" + ts
+}
+
+// rememberMessages adds messages which are not in the hash store yet
+func rememberMessages(store *state.Store, teamId string, channelId string, messages []model.Conversation) error {
+	for _, m := range messages {
+		key := messageKey(teamId, channelId, m.TimeStamp)
+		var rec contentRecord
+		found, err := store.Get(key, &rec)
+		if err != nil {
+			return err
+		}
+		if !found {
+			if err = store.Set(key, contentRecord{Hash: contentHash(m), Text: m.Text, Original: m.Text, User: m.User}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func appendChange(client *logclient.LogClient, change model.ConversationChange) error {
+	data, errJson := json.Marshal(change)
+	if errJson != nil {
+		return errJson
+	}
+	lm, keep := client.NewLogs(changeLogtype, time.Now().Unix(), data)
+	if !keep {
+		return nil
+	}
+	totalLogsSize = totalLogsSize + len(lm.Message)
+	logCount = logCount + 1
+	logs[changeLogtype] = append(logs[changeLogtype], lm)
+	return nil
+}
+
+// trackedKey holds the unix time from which the hash store has every message of a channel. Messages
+// after it which are not in the store were missed by the polling, older ones predate the store.
+func trackedKey(teamId string, channelId string) string {
+	return "tracked/" + teamId + "/" + channelId
+}
+
+// reconcile compares the messages of a channel between oldest and latest with the hash store. It exports
+// edits, deletions and messages the polling missed, and forgets messages older than the window. The
+// messages from fetchedOldest on were fetched by the polling already, only the older part of the window
+// is fetched again. It returns the messages with replies of the older part, for thread collection.
+func (cl *ConversationLogsHandler) reconcile(token string, teamId string, teamName string, channelId string, channelName string, channelType string, fetched []model.Conversation, oldest int64, fetchedOldest int64, latest int64) ([]model.Conversation, error) {
+	var trackedSince int64
+	found, err := cl.hashes.Get(trackedKey(teamId, channelId), &trackedSince)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		// The polling collects the messages of its window, so the store is complete from there on
+		trackedSince = fetchedOldest
+		if err = cl.hashes.Set(trackedKey(teamId, channelId), trackedSince); err != nil {
+			return nil, err
+		}
+	}
+
+	seen := make(map[string]bool)
+	var missed []model.Conversation
+	compare := func(messages []model.Conversation) error {
+		for _, m := range messages {
+			key := messageKey(teamId, channelId, m.TimeStamp)
+			// A deleted parent message with replies remains as a tombstone
+			if m.SubType == "tombstone" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			var rec contentRecord
+			found, err := cl.hashes.Get(key, &rec)
+			if err != nil {
+				return err
+			}
+			hash := contentHash(m)
+			if !found {
+				if parseTs(m.TimeStamp) >= float64(trackedSince) {
+					missed = append(missed, m)
+				}
+				err = cl.hashes.Set(key, contentRecord{Hash: hash, Text: m.Text, Original: m.Text, User: m.User})
+			} else if rec.Hash != hash {
+				change := model.ConversationChange{ChangeType: "message_edited", ChannelID: channelId, ChannelName: channelName, ChannelType: channelType,
+					TeamName: teamName, TimeStamp: m.TimeStamp, User: m.User, Text: m.Text, PreviousText: rec.Text, OriginalText: rec.Original}
+				if m.Edited != nil {
+					change.EditedBy = m.Edited.User
+					change.EditedTS = m.Edited.TimeStamp
+				}
+				if err = appendChange(cl.Client, change); err != nil {
+					return err
+				}
+				err = cl.hashes.Set(key, contentRecord{Hash: hash, Text: m.Text, Original: rec.Original, User: rec.User})
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err = compare(fetched); err != nil {
+		return nil, err
+	}
+	var parents []model.Conversation
+	nextCursor := ""
+	for oldest < fetchedOldest {
+		c := common.NewSlackClient(constants.SlackChannelHistoryAPIURL, token, nextCursor)
+		response, err := getSlackConversationLogs(c, channelId, oldest, fetchedOldest)
+		if err != nil {
+			return nil, err
+		}
+		if err = compare(response.ConversationsList); err != nil {
+			return nil, err
+		}
+		for _, m := range response.ConversationsList {
+			if m.ReplyCount > 0 {
+				parents = append(parents, m)
+			}
+		}
+		if totalLogsSize >= constants.MaxAllowed {
+			cl.ResetLogs()
+		}
+		nextCursor = response.ResponseMetaData.NextCursor
+		if nextCursor == "" {
+			break
+		}
+	}
+	if len(missed) > 0 {
+		slog.Info("Exporting messages missed by polling", "channel", channelId, "count", len(missed))
+		if err = transformConversationLogs(cl.Client, missed, teamName, channelId, channelName, channelType); err != nil {
+			return nil, err
+		}
+	}
+
+	prefix := messageKey(teamId, channelId, "")
+	for _, key := range cl.hashes.Keys(prefix) {
+		ts, err := strconv.ParseFloat(strings.TrimPrefix(key, prefix), 64)
+		if err != nil || ts < float64(oldest) {
+			// Out of the window, edits and deletions of older messages are not tracked
+			cl.hashes.Delete(key)
+			continue
+		}
+		if ts > float64(latest) || seen[key] {
+			continue
+		}
+		var rec contentRecord
+		if _, err = cl.hashes.Get(key, &rec); err != nil {
+			return nil, err
+		}
+		change := model.ConversationChange{ChangeType: "message_deleted", ChannelID: channelId, ChannelName: channelName, ChannelType: channelType,
+			TeamName: teamName, TimeStamp: strings.TrimPrefix(key, prefix), User: rec.User, PreviousText: rec.Text, OriginalText: rec.Original}
+		if err = appendChange(cl.Client, change); err != nil {
+			return nil, err
+		}
+		cl.hashes.Delete(key)
+	}
+	return parents, nil
+}
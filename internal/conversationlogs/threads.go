@@ -0,0 +1,116 @@
+package conversationlogs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"slackLogs/internal/common"
+	"slackLogs/internal/constants"
+	"slackLogs/internal/model"
+)
+
+// Replies are collected per thread and only once: a thread is checked when its parent message is
+// fetched with a latest_reply after the last reply collected from it, and only the replies after
+// that are fetched. Each reply is exported as its own log linked by thread_ts.
+
+func threadKey(teamId string, channelId string, threadTs string) string {
+	return teamId + "/" + channelId + "/" + threadTs
+}
+
+// parseTs converts a Slack message ts such as "1700000000.000100" for comparisons
+func parseTs(ts string) float64 {
+	value, err := strconv.ParseFloat(ts, 64)
+	if err != nil {
+		return 0
+	}
+	return value
+}
+
+// getReplies returns the replies of a thread posted after oldest, oldest is empty for all replies
+func getReplies(token string, channelId string, threadTs string, oldest string) ([]model.Conversation, error) {
+	nextCursor := ""
+	var repliesList []model.Conversation
+	for {
+		slackClient := common.NewSlackClient(constants.SlackChannelRepliesAPIURL, token, nextCursor)
+		params := map[string]string{
+			"channel": channelId,
+			"limit":   strconv.Itoa(200),
+			"ts":      threadTs,
+		}
+		if oldest != "" {
+			params["oldest"] = oldest
+			params["inclusive"] = strconv.FormatBool(false)
+		}
+		var responseData conversationsReplyResponse
+		errSlack := slackClient.SendRequest(common.WaitAndRetry, &responseData, params)
+		if errSlack != nil {
+			return repliesList, errSlack
+		}
+		if !responseData.Ok {
+			return repliesList, fmt.Errorf("Slack API error %v", responseData.ReqError)
+		}
+		for _, reply := range responseData.RepliesList {
+			// The parent message is part of every response
+			if reply.TimeStamp == threadTs || parseTs(reply.TimeStamp) <= parseTs(oldest) {
+				continue
+			}
+			repliesList = append(repliesList, reply)
+		}
+		nextCursor = responseData.ResponseMetaData.NextCursor
+		if !responseData.HasMoreData || nextCursor == "" {
+			return repliesList, nil
+		}
+	}
+}
+
+// collectThreadReplies exports the replies of the given parents which were not collected yet and forgets
+// threads which started before the thread window. Parents are the messages with replies of the history
+// fetched in this iteration, remembered threads are skipped unless their parent has new activity.
+func (cl *ConversationLogsHandler) collectThreadReplies(token string, teamId string, teamName string, channelId string, channelName string, channelType string, parents []model.Conversation, oldest int64) error {
+	prefix := threadKey(teamId, channelId, "")
+	for _, key := range cl.threads.Keys(prefix) {
+		if parseTs(strings.TrimPrefix(key, prefix)) < float64(oldest) {
+			cl.threads.Delete(key)
+		}
+	}
+
+	checked := make(map[string]bool)
+	for _, parent := range parents {
+		if checked[parent.TimeStamp] || parseTs(parent.TimeStamp) < float64(oldest) {
+			continue
+		}
+		checked[parent.TimeStamp] = true
+		key := threadKey(teamId, channelId, parent.TimeStamp)
+		var lastSeen string
+		if _, err := cl.threads.Get(key, &lastSeen); err != nil {
+			return err
+		}
+		if lastSeen != "" && parseTs(parent.LatestReply) <= parseTs(lastSeen) {
+			continue
+		}
+		replies, err := getReplies(token, channelId, parent.TimeStamp, lastSeen)
+		if err != nil {
+			return fmt.Errorf("Error while getting replies for channel %s -  %v", channelId, err)
+		}
+		// Threads without new replies, e.g. whose replies were deleted, are remembered with their
+		// latest_reply as well, so they are not fetched again until there is a newer reply
+		collected := parent.LatestReply
+		if len(replies) > 0 {
+			if err = transformConversationLogs(cl.Client, replies, teamName, channelId, channelName, channelType); err != nil {
+				return err
+			}
+			collected = replies[len(replies)-1].TimeStamp
+		}
+		if parseTs(collected) > parseTs(lastSeen) {
+			if err = cl.threads.Set(key, collected); err != nil {
+				return err
+			}
+		}
+		// Check total collected logs size and maximum allowed logs size in a single request
+		if totalLogsSize >= constants.MaxAllowed {
+			cl.ResetLogs()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package directory
+
+import (
+	"sync"
+
+	"slackLogs/internal/channelregistry"
+)
+
+// User is what the directory keeps of a Slack user
+type User struct {
+	ID       string
+	Name     string
+	RealName string
+	Email    string
+}
+
+// Directory resolves user and channel IDs to names. Users are refreshed by the UserLogs collector,
+// channels are read from the channel registry.
+type Directory struct {
+	mux      sync.RWMutex
+	users    map[string]User            // user id -> user
+	teams    map[string]map[string]bool // team id -> ids of the team's users, to forget removed users
+	channels *channelregistry.Registry
+}
+
+func New(channels *channelregistry.Registry) *Directory {
+	return &Directory{users: make(map[string]User), teams: make(map[string]map[string]bool), channels: channels}
+}
+
+// SetUsers replaces the users of a team
+func (d *Directory) SetUsers(teamId string, users []User) {
+	ids := make(map[string]bool, len(users))
+	for _, u := range users {
+		ids[u.ID] = true
+	}
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	for id := range d.teams[teamId] {
+		if !ids[id] {
+			delete(d.users, id)
+		}
+	}
+	for _, u := range users {
+		d.users[u.ID] = u
+	}
+	d.teams[teamId] = ids
+}
+
+// User returns a user by id
+func (d *Directory) User(id string) (User, bool) {
+	d.mux.RLock()
+	defer d.mux.RUnlock()
+	u, ok := d.users[id]
+	return u, ok
+}
+
+// ChannelName returns the name of a channel of any team
+func (d *Directory) ChannelName(id string) (string, bool) {
+	return d.channels.ChannelName(id)
+}
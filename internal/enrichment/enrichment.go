@@ -0,0 +1,110 @@
+package enrichment
+
+import (
+	"regexp"
+
+	"slackLogs/internal/directory"
+)
+
+// Enricher resolves the user and channel IDs of records to names from the directory
+type Enricher struct {
+	Directory *directory.Directory
+	// Rewrites <@U123> and <#C123|name> mentions in message text to @name and #name
+	RewriteMentions bool
+	// Adds user_email next to user_name, emails are personal data and left out by default
+	IncludeEmail bool
+}
+
+func NewEnricher(d *directory.Directory, rewriteMentions bool, includeEmail bool) *Enricher {
+	return &Enricher{Directory: d, RewriteMentions: rewriteMentions, IncludeEmail: includeEmail}
+}
+
+// Fields holding user ids and channel ids, e.g. user of conversation logs and events,
+// user_id of file and membership logs, channel of events
+var (
+	userFields    = []string{"user", "user_id"}
+	channelFields = []string{"channel", "channel_id", "ChannelID"}
+	textFields    = []string{"text", "previous_text", "original_text"}
+)
+
+// Mentions as written in message text: <@U123>, <@U123|label>, <#C123>, <#C123|name>
+var mentionPattern = regexp.MustCompile(`<([@#])([UWCGD][A-Z0-9]+)(?:\|([^>]*))?>`)
+
+func stringField(record map[string]interface{}, field string) string {
+	value, _ := record[field].(string)
+	return value
+}
+
+func (e *Enricher) Process(logtype string, record map[string]interface{}) bool {
+	e.addUser(record)
+	e.addChannel(record)
+	if e.RewriteMentions {
+		for _, field := range textFields {
+			if text := stringField(record, field); text != "" {
+				record[field] = e.rewriteMentions(text)
+			}
+		}
+	}
+	return true
+}
+
+// addUser adds user_name and, with IncludeEmail, user_email unless the record has a user_name already
+func (e *Enricher) addUser(record map[string]interface{}) {
+	if _, ok := record["user_name"]; ok {
+		return
+	}
+	for _, field := range userFields {
+		if u, ok := e.Directory.User(stringField(record, field)); ok {
+			record["user_name"] = u.Name
+			if e.IncludeEmail && u.Email != "" {
+				record["user_email"] = u.Email
+			}
+			return
+		}
+	}
+}
+
+// addChannel adds channel_name unless the record has the channel name already
+func (e *Enricher) addChannel(record map[string]interface{}) {
+	if _, ok := record["channel_name"]; ok {
+		return
+	}
+	if _, ok := record["ChannelName"]; ok {
+		return
+	}
+	ids := []string{}
+	for _, field := range channelFields {
+		ids = append(ids, stringField(record, field))
+	}
+	// Audit logs of channel actions carry the channel in the entity
+	if entity, ok := record["entity"].(map[string]interface{}); ok {
+		if channel, ok := entity["channel"].(map[string]interface{}); ok {
+			ids = append(ids, stringField(channel, "id"))
+		}
+	}
+	for _, id := range ids {
+		if name, ok := e.Directory.ChannelName(id); ok {
+			record["channel_name"] = name
+			return
+		}
+	}
+}
+
+func (e *Enricher) rewriteMentions(text string) string {
+	return mentionPattern.ReplaceAllStringFunc(text, func(mention string) string {
+		m := mentionPattern.FindStringSubmatch(mention)
+		sigil, id, label := m[1], m[2], m[3]
+		if sigil == "@" {
+			if u, ok := e.Directory.User(id); ok {
+				return "@" + u.Name
+			}
+		} else if name, ok := e.Directory.ChannelName(id); ok {
+			return "#" + name
+		}
+		if label != "" {
+			return sigil + label
+		}
+		// Unknown ids stay as they are
+		return mention
+	})
+}
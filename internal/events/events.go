@@ -0,0 +1,207 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"slackLogs/internal/constants"
+	"slackLogs/internal/logclient"
+	"slackLogs/internal/model"
+)
+
+var logtype = "EventLog"
+
+// Slack retries deliveries it considers failed, event ids are remembered this long to drop duplicates
+const dedupeWindow = time.Hour
+
+// TeamResolver returns the log client (and with it the workspace) and the team name for events of a team
+type TeamResolver func(teamId string) (*logclient.LogClient, string)
+
+// Processor turns Events API payloads into logs and exports them in batches.
+// It is fed by the HTTP receiver and by Socket Mode.
+type Processor struct {
+	mux     sync.Mutex
+	resolve TeamResolver
+	pending map[*logclient.LogClient]*pendingLogs
+	seen    map[string]time.Time // event id -> time received
+}
+
+type pendingLogs struct {
+	logs []logclient.Logs
+	size int
+}
+
+// callbackEnvelope is the outer payload of an Events API request
+// https://api.slack.com/apis/connections/events-api#receiving-events
+type callbackEnvelope struct {
+	Type         string          `json:"type"`
+	Challenge    string          `json:"challenge"`
+	TeamID       string          `json:"team_id"`
+	EnterpriseID string          `json:"enterprise_id"`
+	EventID      string          `json:"event_id"`
+	EventTime    int64           `json:"event_time"`
+	Event        json.RawMessage `json:"event"`
+}
+
+// NewProcessor creates a processor which flushes pending logs every flushInterval
+func NewProcessor(resolve TeamResolver, flushInterval time.Duration) *Processor {
+	p := &Processor{
+		resolve: resolve,
+		pending: make(map[*logclient.LogClient]*pendingLogs),
+		seen:    make(map[string]time.Time),
+	}
+	go func() {
+		for range time.Tick(flushInterval) {
+			p.Flush()
+		}
+	}()
+	return p
+}
+
+// HandlePayload processes an event_callback payload, other payload types are ignored.
+// It doesn't wait for exports, full batches are exported in the background.
+func (p *Processor) HandlePayload(payload []byte) error {
+	var envelope callbackEnvelope
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		return err
+	}
+	if envelope.Type != "event_callback" {
+		slog.Debug("Ignoring Events API payload", "type", envelope.Type)
+		return nil
+	}
+	// Redeliveries are dropped before they reach the processors of the log client
+	p.mux.Lock()
+	if _, duplicate := p.seen[envelope.EventID]; duplicate && envelope.EventID != "" {
+		p.mux.Unlock()
+		slog.Debug("Dropping duplicate event delivery", "eventId", envelope.EventID)
+		return nil
+	}
+	p.seen[envelope.EventID] = time.Now()
+	p.mux.Unlock()
+	event, err := buildEvent(envelope)
+	if err != nil {
+		return err
+	}
+	client, teamName := p.resolve(envelope.TeamID)
+	event.TeamName = teamName
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	lm, keep := client.NewLogs(logtype, time.Now().Unix(), data)
+	if !keep {
+		return nil
+	}
+
+	p.mux.Lock()
+	pl, ok := p.pending[client]
+	if !ok {
+		pl = &pendingLogs{}
+		p.pending[client] = pl
+	}
+	pl.logs = append(pl.logs, lm)
+	pl.size = pl.size + len(lm.Message)
+	var full []logclient.Logs
+	if pl.size >= constants.MaxAllowed {
+		full = pl.logs
+		delete(p.pending, client)
+	}
+	p.mux.Unlock()
+
+	// Export in a goroutine, receivers have to acknowledge the event within Slack's 3 seconds and
+	// a Log API request can take longer than that
+	if full != nil {
+		go export(client, full)
+	}
+	return nil
+}
+
+// Flush exports all pending logs
+func (p *Processor) Flush() {
+	p.mux.Lock()
+	pending := p.pending
+	p.pending = make(map[*logclient.LogClient]*pendingLogs)
+	for id, received := range p.seen {
+		if time.Since(received) > dedupeWindow {
+			delete(p.seen, id)
+		}
+	}
+	p.mux.Unlock()
+
+	for client, pl := range pending {
+		export(client, pl.logs)
+	}
+}
+
+func export(client *logclient.LogClient, logs []logclient.Logs) {
+	if err := client.Flush(logtype, logs); err != nil {
+		slog.Error("Not able to export events", "count", len(logs), "error", err)
+	}
+}
+
+func getString(m map[string]interface{}, key string) string {
+	if v, ok := m[key].(string); ok {
+		return v
+	}
+	return ""
+}
+
+func getObject(m map[string]interface{}, key string) map[string]interface{} {
+	if v, ok := m[key].(map[string]interface{}); ok {
+		return v
+	}
+	return map[string]interface{}{}
+}
+
+// buildEvent flattens the fields of the common event types into the record
+// https://api.slack.com/events
+func buildEvent(envelope callbackEnvelope) (model.Event, error) {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(envelope.Event, &payload); err != nil {
+		return model.Event{}, fmt.Errorf("error parsing event %s: %v", envelope.EventID, err)
+	}
+	event := model.Event{
+		EventID:      envelope.EventID,
+		EventTime:    envelope.EventTime,
+		TeamID:       envelope.TeamID,
+		EnterpriseID: envelope.EnterpriseID,
+		Type:         getString(payload, "type"),
+		Subtype:      getString(payload, "subtype"),
+		ChannelType:  getString(payload, "channel_type"),
+		TimeStamp:    getString(payload, "ts"),
+		Event:        payload,
+	}
+	// channel and user are IDs for most events, but objects for e.g. channel_created and user_change
+	if channel, ok := payload["channel"].(string); ok {
+		event.Channel = channel
+	} else {
+		event.Channel = getString(getObject(payload, "channel"), "id")
+	}
+	if user, ok := payload["user"].(string); ok {
+		event.User = user
+	} else {
+		event.User = getString(getObject(payload, "user"), "id")
+	}
+
+	switch event.Subtype {
+	case "message_changed":
+		message := getObject(payload, "message")
+		event.User = getString(message, "user")
+		event.Text = getString(message, "text")
+		event.ThreadTS = getString(message, "thread_ts")
+		event.PreviousText = getString(getObject(payload, "previous_message"), "text")
+	case "message_deleted":
+		event.DeletedTS = getString(payload, "deleted_ts")
+		event.PreviousText = getString(getObject(payload, "previous_message"), "text")
+	default:
+		event.Text = getString(payload, "text")
+		event.ThreadTS = getString(payload, "thread_ts")
+	}
+	if event.Type == "channel_created" {
+		event.User = getString(getObject(payload, "channel"), "creator")
+	}
+	return event, nil
+}
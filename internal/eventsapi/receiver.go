@@ -0,0 +1,116 @@
+package eventsapi
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"slackLogs/internal/secrets"
+)
+
+const (
+	// Slack signs requests with a timestamp, older requests are rejected to prevent replays
+	maxRequestAge  = 5 * time.Minute
+	maxRequestSize = 1 << 20
+)
+
+// PayloadHandler processes the payload of a verified callback, e.g. events.Processor
+type PayloadHandler interface {
+	HandlePayload(payload []byte) error
+}
+
+// callbackEnvelope holds the fields of a callback the receiver needs itself
+// https://api.slack.com/apis/connections/events-api#receiving-events
+type callbackEnvelope struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	EventID   string `json:"event_id"`
+}
+
+// Receiver is the HTTP endpoint for Events API callbacks
+// https://api.slack.com/apis/connections/events-api
+type Receiver struct {
+	processor     PayloadHandler
+	signingSecret *secrets.Secret
+}
+
+func NewReceiver(processor PayloadHandler, signingSecret *secrets.Secret) *Receiver {
+	return &Receiver{processor: processor, signingSecret: signingSecret}
+}
+
+// ListenAndServe serves Events API callbacks on path
+func (r *Receiver) ListenAndServe(address string, path string) error {
+	mux := http.NewServeMux()
+	mux.Handle(path, r)
+	server := &http.Server{
+		Addr:              address,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	slog.Info("Listening for Slack Events API callbacks", "address", address, "path", path)
+	return server.ListenAndServe()
+}
+
+// verifySignature checks the X-Slack-Signature of a request
+// https://api.slack.com/authentication/verifying-requests-from-slack
+func verifySignature(signingSecret string, timestamp string, signature string, body []byte, now time.Time) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("invalid X-Slack-Request-Timestamp")
+	}
+	age := now.Sub(time.Unix(ts, 0))
+	if age > maxRequestAge || age < -maxRequestAge {
+		return errors.New("request timestamp is too old")
+	}
+	mac := hmac.New(sha256.New, []byte(signingSecret))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return errors.New("invalid X-Slack-Signature")
+	}
+	return nil
+}
+
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, req.Body, maxRequestSize))
+	if err != nil {
+		http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	err = verifySignature(r.signingSecret.Value(), req.Header.Get("X-Slack-Request-Timestamp"), req.Header.Get("X-Slack-Signature"), body, time.Now())
+	if err != nil {
+		slog.Warn("Rejected Events API request", "remoteAddr", req.RemoteAddr, "error", err)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	var envelope callbackEnvelope
+	if err = json.Unmarshal(body, &envelope); err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+	if envelope.Type == "url_verification" {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(envelope.Challenge))
+		return
+	}
+	if err = r.processor.HandlePayload(body); err != nil {
+		slog.Error("Not able to process Events API payload", "eventId", envelope.EventID, "error", err)
+		http.Error(w, "invalid event", http.StatusBadRequest)
+		return
+	}
+	// Slack expects an acknowledgement within 3 seconds, HandlePayload returns before events are exported
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,133 @@
+package eventsapi
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"slackLogs/internal/secrets"
+)
+
+const testSigningSecret = "8f742231b10e8888abcd99yyyzzz85a5"
+
+// sign signs a request body the way Slack does
+func sign(secret string, timestamp string, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":" + body))
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	body := `{"type":"event_callback","event_id":"Ev1"}`
+	old := strconv.FormatInt(now.Add(-maxRequestAge-time.Second).Unix(), 10)
+	future := strconv.FormatInt(now.Add(maxRequestAge+time.Second).Unix(), 10)
+	recent := strconv.FormatInt(now.Add(-time.Minute).Unix(), 10)
+	tests := []struct {
+		name      string
+		timestamp string
+		signature string
+		body      string
+		valid     bool
+	}{
+		{"valid", timestamp, sign(testSigningSecret, timestamp, body), body, true},
+		{"valid within the maximum age", recent, sign(testSigningSecret, recent, body), body, true},
+		{"other secret", timestamp, sign("other", timestamp, body), body, false},
+		{"changed body", timestamp, sign(testSigningSecret, timestamp, body), body + " ", false},
+		{"signature of another timestamp", timestamp, sign(testSigningSecret, recent, body), body, false},
+		{"without version", timestamp, strings.TrimPrefix(sign(testSigningSecret, timestamp, body), "v0="), body, false},
+		{"missing signature", timestamp, "", body, false},
+		{"stale timestamp", old, sign(testSigningSecret, old, body), body, false},
+		{"future timestamp", future, sign(testSigningSecret, future, body), body, false},
+		{"invalid timestamp", "yesterday", sign(testSigningSecret, "yesterday", body), body, false},
+		{"missing timestamp", "", sign(testSigningSecret, "", body), body, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifySignature(testSigningSecret, tt.timestamp, tt.signature, []byte(tt.body), now)
+			if tt.valid && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+// recordingHandler keeps the payloads handed to the processor
+type recordingHandler struct {
+	payloads []string
+	err      error
+}
+
+func (h *recordingHandler) HandlePayload(payload []byte) error {
+	h.payloads = append(h.payloads, string(payload))
+	return h.err
+}
+
+func TestServeHTTP(t *testing.T) {
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+	challenge := `{"type":"url_verification","token":"t","challenge":"3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P"}`
+	callback := `{"type":"event_callback","team_id":"T1","event_id":"Ev1","event":{"type":"message","text":"hi"}}`
+	tests := []struct {
+		name       string
+		method     string
+		timestamp  string
+		signature  string
+		body       string
+		handlerErr error
+		wantStatus int
+		wantBody   string
+		wantHandle bool
+	}{
+		{"url verification", http.MethodPost, now, "", challenge, nil, http.StatusOK, "3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P", false},
+		{"unsigned url verification", http.MethodPost, now, "v0=00", challenge, nil, http.StatusUnauthorized, "", false},
+		{"stale url verification", http.MethodPost, stale, "", challenge, nil, http.StatusUnauthorized, "", false},
+		{"event callback", http.MethodPost, now, "", callback, nil, http.StatusOK, "", true},
+		{"stale event callback", http.MethodPost, stale, "", callback, nil, http.StatusUnauthorized, "", false},
+		{"event callback failing", http.MethodPost, now, "", callback, errors.New("invalid event"), http.StatusBadRequest, "", true},
+		{"invalid payload", http.MethodPost, now, "", "{", nil, http.StatusBadRequest, "", false},
+		{"get", http.MethodGet, now, "", "", nil, http.StatusMethodNotAllowed, "", false},
+	}
+	signingSecret, err := secrets.Parse(testSigningSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &recordingHandler{err: tt.handlerErr}
+			receiver := NewReceiver(handler, signingSecret)
+			req := httptest.NewRequest(tt.method, "/slack/events", strings.NewReader(tt.body))
+			signature := tt.signature
+			if signature == "" {
+				signature = sign(testSigningSecret, tt.timestamp, tt.body)
+			}
+			req.Header.Set("X-Slack-Request-Timestamp", tt.timestamp)
+			req.Header.Set("X-Slack-Signature", signature)
+			rec := httptest.NewRecorder()
+			receiver.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+			if handled := len(handler.payloads) > 0; handled != tt.wantHandle {
+				t.Fatalf("payload handed to the processor = %v, want %v", handled, tt.wantHandle)
+			}
+			if tt.wantHandle && handler.payloads[0] != tt.body {
+				t.Errorf("payload = %s, want %s", handler.payloads[0], tt.body)
+			}
+		})
+	}
+}
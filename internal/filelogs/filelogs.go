@@ -0,0 +1,217 @@
+package filelogs
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"slackLogs/internal/channelregistry"
+	"slackLogs/internal/common"
+	"slackLogs/internal/constants"
+	"slackLogs/internal/logclient"
+	"slackLogs/internal/model"
+	"slackLogs/internal/state"
+)
+
+var (
+	totalLogsSize = 0
+	logtype       = "FileLog"
+	logCount      = 0 // This variable helps to track number of logs exported in each request
+	pageSize      = 100
+)
+
+var logs = []logclient.Logs{}
+
+type FileLogsHandler struct {
+	Client          *logclient.LogClient
+	Registry        *channelregistry.Registry // Channel names and Slack Connect flags
+	PollingInterval time.Duration
+	checkpoints     *state.Store      // team id -> end of the last collected time window
+	userNames       map[string]string // user id -> user name, kept across iterations
+}
+
+func NewFileLogsHandler(client *logclient.LogClient, registry *channelregistry.Registry, pollingInterval time.Duration) (*FileLogsHandler, error) {
+	checkpoints, err := state.Open("fileLogs")
+	if err != nil {
+		return nil, err
+	}
+	return &FileLogsHandler{Client: client, Registry: registry, PollingInterval: pollingInterval, checkpoints: checkpoints, userNames: make(map[string]string)}, nil
+}
+
+// filesListResponse contains slack API successful response
+// https://api.slack.com/methods/files.list#examples
+type filesListResponse struct {
+	Ok     bool         `json:"ok"`
+	Files  []model.File `json:"files"`
+	Paging struct {
+		Count int `json:"count"`
+		Total int `json:"total"`
+		Page  int `json:"page"`
+		Pages int `json:"pages"`
+	} `json:"paging"`
+	ReqError string                 `json:"error"`
+	Random   map[string]interface{} `json:"-"`
+}
+
+// usersInfoResponse contains slack API successful response
+// https://api.slack.com/methods/users.info#examples
+type usersInfoResponse struct {
+	Ok       bool       `json:"ok"`
+	User     model.User `json:"user"`
+	ReqError string     `json:"error"`
+}
+
+func getSlackFileLogs(c *common.SlackClient, teamId string, from int64, to int64, page int) (filesListResponse, error) {
+	params := map[string]string{
+		"team_id": teamId,
+		"ts_from": strconv.FormatInt(from, 10),
+		"ts_to":   strconv.FormatInt(to, 10),
+		"count":   strconv.Itoa(pageSize),
+		"page":    strconv.Itoa(page),
+	}
+	var responseData filesListResponse
+	errSlack := c.SendRequest(common.WaitAndRetry, &responseData, params)
+	if errSlack != nil {
+		return responseData, errSlack
+	}
+	if !responseData.Ok {
+		return responseData, fmt.Errorf("Slack API error %v", responseData.ReqError)
+	}
+	return responseData, nil
+}
+
+// getUserName looks up the name of a file owner once and keeps it for later iterations
+func (fl *FileLogsHandler) getUserName(token string, userId string) string {
+	if userId == "" {
+		return ""
+	}
+	if name, ok := fl.userNames[userId]; ok {
+		return name
+	}
+	slackClient := common.NewSlackClient(constants.SlackUserInfoAPIURL, token, "")
+	params := map[string]string{
+		"user": userId,
+	}
+	var responseData usersInfoResponse
+	errSlack := slackClient.SendRequest(common.WaitAndRetry, &responseData, params)
+	if errSlack == nil && !responseData.Ok {
+		errSlack = fmt.Errorf("Slack API error %v", responseData.ReqError)
+	}
+	if errSlack != nil {
+		// The file is still exported, only without the owner's name
+		slog.Warn("Not able to get the name of a file owner", "user", userId, "error", errSlack)
+		return ""
+	}
+	fl.userNames[userId] = responseData.User.Name
+	return responseData.User.Name
+}
+
+// enrichFile adds user and channel names and flags files which are shared outside of the organization
+// with the channels discovered for the team, nil when they are not discovered yet
+func (fl *FileLogsHandler) enrichFile(token string, f *model.File, channels *channelregistry.Snapshot, teamName string) {
+	f.TeamName = teamName
+	f.UserName = fl.getUserName(token, f.UserID)
+	f.ChannelNames = []string{}
+	f.ExternalChannels = []string{}
+	if channels != nil {
+		for _, id := range append(append([]string{}, f.Channels...), f.Groups...) {
+			if name, ok := channels.Name(id); ok {
+				f.ChannelNames = append(f.ChannelNames, name)
+			}
+			if channels.IsExtShared(id) {
+				f.ExternalChannels = append(f.ExternalChannels, id)
+			}
+		}
+	}
+	f.SharedExternally = f.PublicURLShared || len(f.ExternalChannels) > 0
+}
+
+func (fl *FileLogsHandler) transformFileLogs(token string, files []model.File, teamId string, teamName string) error {
+	ts := time.Now().Unix()
+	channels, _ := fl.Registry.Latest(teamId)
+	for _, f := range files {
+		fl.enrichFile(token, &f, channels, teamName)
+		data, errJson := json.Marshal(f)
+		if errJson != nil {
+			return errJson
+		}
+		lm, keep := fl.Client.NewLogs(logtype, ts, data)
+		if !keep {
+			continue
+		}
+		totalLogsSize = totalLogsSize + len(lm.Message)
+		logCount = logCount + 1
+		logs = append(logs, lm)
+	}
+	return nil
+}
+
+// flushLogs exports the buffered logs, the buffer is emptied also when the export fails
+func (fl *FileLogsHandler) flushLogs() error {
+	var err error
+	if len(logs) > 0 {
+		err = fl.Client.Flush(logtype, logs)
+	}
+	logs = []logclient.Logs{}
+	totalLogsSize = 0
+	logCount = 0
+	return err
+}
+
+func (fl *FileLogsHandler) ResetLogs() {
+	if err := fl.flushLogs(); err != nil {
+		slog.Error("Not able to export file logs", "error", err)
+	}
+}
+
+func (fl *FileLogsHandler) Collect(token string, teamId string, teamName string) error {
+	logCount = 0
+	var from int64
+	found, err := fl.checkpoints.Get(teamId, &from)
+	if err != nil {
+		return err
+	}
+	now := time.Now().Unix()
+	if !found {
+		// First run, collect the last polling interval like the other collectors
+		from = time.Now().Add(-(fl.PollingInterval)).Unix()
+	}
+	// The window ends a second before now, the next window starts at now
+	to := now - 1
+	slog.Info("Collecting file logs", "team", teamName, "from", from, "to", to)
+	for page := 1; ; page++ {
+		c := common.NewSlackClient(constants.SlackFilesListAPIURL, token, "")
+		// Get files created within the time window
+		response, err := getSlackFileLogs(c, teamId, from, to, page)
+		if err != nil {
+			return err
+		}
+		// Enrich files and add timestamp to each log
+		err = fl.transformFileLogs(token, response.Files, teamId, teamName)
+		if err != nil {
+			return err
+		}
+		// Check total collected logs size and maximum allowed logs size in a single request
+		if totalLogsSize >= constants.MaxAllowed {
+			if err = fl.flushLogs(); err != nil {
+				slog.Error("Not able to export file logs, they are collected again in the next iteration", "team", teamName, "error", err)
+				return nil
+			}
+		}
+		if page >= response.Paging.Pages {
+			slog.Debug("There is no next page, collected fileLogs")
+			break
+		}
+	}
+	// Flush rest of the logs, the checkpoint moves only when all of them were exported
+	if err = fl.flushLogs(); err != nil {
+		slog.Error("Not able to export file logs, they are collected again in the next iteration", "team", teamName, "error", err)
+		return nil
+	}
+	if err = fl.checkpoints.Set(teamId, now); err != nil {
+		return err
+	}
+	return fl.checkpoints.Save()
+}
@@ -0,0 +1,270 @@
+package gridlogs
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"slackLogs/internal/common"
+	"slackLogs/internal/constants"
+	"slackLogs/internal/logclient"
+	"slackLogs/internal/model"
+)
+
+// The admin.* methods need an org-level token of an Enterprise Grid org.
+// https://api.slack.com/enterprise/grid
+
+// pageFunc pages through an admin method and passes each page of records to page
+type pageFunc func(token string, teamId string, teamName string, page func(records []interface{}) error) error
+
+// GridLogsHandler collects the records of one admin method. Grid collectors may run at the
+// same time, so every handler has its own buffer instead of a package level one.
+type GridLogsHandler struct {
+	Client        *logclient.LogClient
+	logtype       string
+	pages         pageFunc
+	logs          []logclient.Logs
+	totalLogsSize int
+	logCount      int // This variable helps to track number of logs exported in each request
+}
+
+func newGridLogsHandler(client *logclient.LogClient, logtype string, pages pageFunc) *GridLogsHandler {
+	return &GridLogsHandler{Client: client, logtype: logtype, pages: pages}
+}
+
+// NewTeamsHandler collects the workspaces of the org, it runs once per org and not per team
+func NewTeamsHandler(client *logclient.LogClient) *GridLogsHandler {
+	return newGridLogsHandler(client, "GridTeam", listTeams)
+}
+
+func NewUsersHandler(client *logclient.LogClient) *GridLogsHandler {
+	return newGridLogsHandler(client, "GridUser", listUsers)
+}
+
+func NewConversationsHandler(client *logclient.LogClient) *GridLogsHandler {
+	return newGridLogsHandler(client, "GridConversation", searchConversations)
+}
+
+func NewAppsHandler(client *logclient.LogClient) *GridLogsHandler {
+	return newGridLogsHandler(client, "GridApp", listApps)
+}
+
+func (gl *GridLogsHandler) transformGridLogs(records []interface{}) error {
+	ts := time.Now().Unix()
+	for _, r := range records {
+		data, errJson := json.Marshal(r)
+		if errJson != nil {
+			return errJson
+		}
+		lm, keep := gl.Client.NewLogs(gl.logtype, ts, data)
+		if !keep {
+			continue
+		}
+		gl.totalLogsSize = gl.totalLogsSize + len(lm.Message)
+		gl.logCount = gl.logCount + 1
+		gl.logs = append(gl.logs, lm)
+	}
+	// Check total collected logs size and maximum allowed logs size in a single request
+	if gl.totalLogsSize >= constants.MaxAllowed {
+		gl.ResetLogs()
+	}
+	return nil
+}
+
+func (gl *GridLogsHandler) ResetLogs() {
+	if len(gl.logs) > 0 {
+		gl.Client.Flush(gl.logtype, gl.logs)
+	}
+	gl.logs = []logclient.Logs{}
+	gl.totalLogsSize = 0
+	gl.logCount = 0
+}
+
+func (gl *GridLogsHandler) Collect(token string, teamId string, teamName string) error {
+	slog.Info("Collecting Grid logs", "logtype", gl.logtype, "team", teamName)
+	gl.logCount = 0
+	err := gl.pages(token, teamId, teamName, gl.transformGridLogs)
+	// Flush rest of the logs
+	gl.ResetLogs()
+	return err
+}
+
+// adminResponse contains the fields shared by the admin.* responses
+type adminResponse struct {
+	Ok               bool   `json:"ok"`
+	ReqError         string `json:"error"`
+	NextCursor       string `json:"next_cursor"` // admin.conversations.search returns the cursor here
+	ResponseMetaData struct {
+		NextCursor string `json:"next_cursor"`
+	} `json:"response_metadata"`
+}
+
+func (r *adminResponse) result() *adminResponse {
+	return r
+}
+
+func (r *adminResponse) next() string {
+	if r.ResponseMetaData.NextCursor != "" {
+		return r.ResponseMetaData.NextCursor
+	}
+	return r.NextCursor
+}
+
+type adminResult interface {
+	result() *adminResponse
+}
+
+func getAdminPage(apiURL string, token string, cursor string, params map[string]string, responseData adminResult) error {
+	slackClient := common.NewSlackClient(apiURL, token, cursor)
+	errSlack := slackClient.SendRequest(common.WaitAndRetry, responseData, params)
+	if errSlack != nil {
+		return errSlack
+	}
+	if !responseData.result().Ok {
+		return fmt.Errorf("Slack API error %v", responseData.result().ReqError)
+	}
+	return nil
+}
+
+// teamsListResponse contains slack API successful response
+// https://api.slack.com/methods/admin.teams.list#examples
+type teamsListResponse struct {
+	adminResponse
+	Teams []model.GridTeam `json:"teams"`
+}
+
+// GetGridTeams returns all workspaces of the org of an org-level token
+func GetGridTeams(token string) ([]model.GridTeam, error) {
+	var teams []model.GridTeam
+	nextCursor := ""
+	for {
+		var responseData teamsListResponse
+		err := getAdminPage(constants.SlackAdminTeamsListAPIURL, token, nextCursor, map[string]string{"limit": "100"}, &responseData)
+		if err != nil {
+			return teams, err
+		}
+		teams = append(teams, responseData.Teams...)
+		nextCursor = responseData.next()
+		if nextCursor == "" {
+			return teams, nil
+		}
+	}
+}
+
+func listTeams(token string, teamId string, orgName string, page func(records []interface{}) error) error {
+	teams, err := GetGridTeams(token)
+	if err != nil {
+		return err
+	}
+	records := make([]interface{}, 0, len(teams))
+	for _, t := range teams {
+		t.OrgName = orgName
+		records = append(records, t)
+	}
+	return page(records)
+}
+
+// usersListResponse contains slack API successful response
+// https://api.slack.com/methods/admin.users.list#examples
+type usersListResponse struct {
+	adminResponse
+	Users []model.GridUser `json:"users"`
+}
+
+func listUsers(token string, teamId string, teamName string, page func(records []interface{}) error) error {
+	nextCursor := ""
+	for {
+		var responseData usersListResponse
+		params := map[string]string{"team_id": teamId, "limit": "100"}
+		if err := getAdminPage(constants.SlackAdminUsersListAPIURL, token, nextCursor, params, &responseData); err != nil {
+			return err
+		}
+		records := make([]interface{}, 0, len(responseData.Users))
+		for _, u := range responseData.Users {
+			u.TeamName = teamName
+			records = append(records, u)
+		}
+		if err := page(records); err != nil {
+			return err
+		}
+		nextCursor = responseData.next()
+		if nextCursor == "" {
+			return nil
+		}
+	}
+}
+
+// conversationsSearchResponse contains slack API successful response
+// https://api.slack.com/methods/admin.conversations.search#examples
+type conversationsSearchResponse struct {
+	adminResponse
+	Conversations []model.GridConversation `json:"conversations"`
+}
+
+func searchConversations(token string, teamId string, teamName string, page func(records []interface{}) error) error {
+	nextCursor := ""
+	for {
+		var responseData conversationsSearchResponse
+		// admin.conversations.search returns at most 20 conversations per page
+		params := map[string]string{"team_ids": teamId, "limit": "20"}
+		if err := getAdminPage(constants.SlackAdminConversationsSearchAPIURL, token, nextCursor, params, &responseData); err != nil {
+			return err
+		}
+		records := make([]interface{}, 0, len(responseData.Conversations))
+		for _, c := range responseData.Conversations {
+			c.TeamName = teamName
+			records = append(records, c)
+		}
+		if err := page(records); err != nil {
+			return err
+		}
+		nextCursor = responseData.next()
+		if nextCursor == "" {
+			return nil
+		}
+	}
+}
+
+// appsListResponse contains slack API successful response of admin.apps.approved.list and admin.apps.restricted.list
+// https://api.slack.com/methods/admin.apps.approved.list#examples
+type appsListResponse struct {
+	adminResponse
+	ApprovedApps   []model.GridApp `json:"approved_apps"`
+	RestrictedApps []model.GridApp `json:"restricted_apps"`
+}
+
+func listApps(token string, teamId string, teamName string, page func(records []interface{}) error) error {
+	statuses := []struct {
+		status string
+		apiURL string
+	}{
+		{"approved", constants.SlackAdminAppsApprovedAPIURL},
+		{"restricted", constants.SlackAdminAppsRestrictedAPIURL},
+	}
+	for _, s := range statuses {
+		nextCursor := ""
+		for {
+			var responseData appsListResponse
+			params := map[string]string{"team_id": teamId, "limit": "100"}
+			if err := getAdminPage(s.apiURL, token, nextCursor, params, &responseData); err != nil {
+				return err
+			}
+			apps := append(responseData.ApprovedApps, responseData.RestrictedApps...)
+			records := make([]interface{}, 0, len(apps))
+			for _, a := range apps {
+				a.Status = s.status
+				a.TeamName = teamName
+				records = append(records, a)
+			}
+			if err := page(records); err != nil {
+				return err
+			}
+			nextCursor = responseData.next()
+			if nextCursor == "" {
+				break
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+package gridlogs
+
+import (
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"slackLogs/internal/constants"
+	"slackLogs/internal/logclient"
+	"slackLogs/internal/model"
+	"slackLogs/internal/state"
+)
+
+// admin.users.session.getSettings accepts at most 50 users per request
+const sessionSettingsBatchSize = 50
+
+// team.accessLogs returns at most 100 pages of 1000 logins
+const maxAccessLogPages = 100
+
+// sessionInventory enriches the active sessions of a team for security review: the country of
+// the session's IP, whether the user is still active and when the session expires
+type sessionInventory struct {
+	allowedCountries map[string]bool // empty allows every country
+	getSettings      bool
+	firstSeen        *state.Store // team id/session id -> unix time the session was first collected
+}
+
+// NewSessionsHandler collects active sessions. Sessions from countries outside of allowedCountries
+// and sessions of deactivated users are flagged. With getSettings the session duration is read
+// with admin.users.session.getSettings to estimate when a session expires.
+func NewSessionsHandler(client *logclient.LogClient, allowedCountries []string, getSettings bool) (*GridLogsHandler, error) {
+	firstSeen, err := state.Open("gridSessions")
+	if err != nil {
+		return nil, err
+	}
+	inv := &sessionInventory{allowedCountries: make(map[string]bool), getSettings: getSettings, firstSeen: firstSeen}
+	for _, c := range allowedCountries {
+		inv.allowedCountries[strings.ToUpper(c)] = true
+	}
+	return newGridLogsHandler(client, "GridSession", inv.listSessions), nil
+}
+
+// sessionListResponse contains slack API successful response
+// https://api.slack.com/methods/admin.users.session.list#examples
+type sessionListResponse struct {
+	adminResponse
+	ActiveSessions []model.GridSession `json:"active_sessions"`
+}
+
+// sessionSettingsResponse contains slack API successful response
+// https://api.slack.com/methods/admin.users.session.getSettings#examples
+type sessionSettingsResponse struct {
+	adminResponse
+	SessionSettings []struct {
+		UserID                string `json:"user_id"`
+		DesktopAppBrowserQuit bool   `json:"desktop_app_browser_quit"`
+		Duration              int64  `json:"duration"`
+	} `json:"session_settings"`
+}
+
+// teamAccessLogResponse contains slack API successful response
+// https://api.slack.com/methods/team.accessLogs#examples
+type teamAccessLogResponse struct {
+	adminResponse
+	Logins []model.AccessLog `json:"logins"`
+	Paging struct {
+		Pages int `json:"pages"`
+	} `json:"paging"`
+}
+
+// getCountries maps the IPs of sessions to their country. The logins of the team are paged from the
+// most recent until every IP is found, IPs without a login are left out.
+func getCountries(token string, teamId string, ips map[string]bool) map[string]string {
+	countries := make(map[string]string)
+	for page := 1; page <= maxAccessLogPages && len(countries) < len(ips); page++ {
+		var responseData teamAccessLogResponse
+		params := map[string]string{"team_id": teamId, "count": "1000", "page": strconv.Itoa(page)}
+		if err := getAdminPage(constants.SlackaccessAPIURL, token, "", params, &responseData); err != nil {
+			slog.Warn("Not able to read team.accessLogs, countries of some sessions are unknown", "error", err)
+			return countries
+		}
+		for _, l := range responseData.Logins {
+			if _, ok := countries[l.IPAddress]; !ok && ips[l.IPAddress] && l.Country != "" {
+				countries[l.IPAddress] = strings.ToUpper(l.Country)
+			}
+		}
+		if page >= responseData.Paging.Pages {
+			break
+		}
+	}
+	return countries
+}
+
+// sessionIP returns the IP of the most recent use of a session
+func sessionIP(s model.GridSession) string {
+	if s.Recent != nil && s.Recent.IP != "" {
+		return s.Recent.IP
+	}
+	if s.Created != nil {
+		return s.Created.IP
+	}
+	return ""
+}
+
+// getActiveUsers returns the users of the team with their active status
+func getActiveUsers(token string, teamId string) (map[string]bool, error) {
+	active := make(map[string]bool)
+	err := listUsers(token, teamId, "", func(records []interface{}) error {
+		for _, r := range records {
+			u := r.(model.GridUser)
+			active[u.ID] = u.IsActive
+		}
+		return nil
+	})
+	return active, err
+}
+
+type sessionSettings struct {
+	desktopAppBrowserQuit bool
+	duration              int64
+}
+
+// getSessionSettings returns the session settings of users which have custom settings
+func getSessionSettings(token string, users []string) map[string]sessionSettings {
+	settings := make(map[string]sessionSettings)
+	for start := 0; start < len(users); start += sessionSettingsBatchSize {
+		end := start + sessionSettingsBatchSize
+		if end > len(users) {
+			end = len(users)
+		}
+		var responseData sessionSettingsResponse
+		params := map[string]string{"user_ids": strings.Join(users[start:end], ",")}
+		if err := getAdminPage(constants.SlackAdminSessionSettingsAPIURL, token, "", params, &responseData); err != nil {
+			slog.Warn("Not able to read session settings, expiry of sessions is unknown", "error", err)
+			return settings
+		}
+		for _, s := range responseData.SessionSettings {
+			settings[s.UserID] = sessionSettings{desktopAppBrowserQuit: s.DesktopAppBrowserQuit, duration: s.Duration}
+		}
+	}
+	return settings
+}
+
+func (inv *sessionInventory) getSessions(token string, teamId string) ([]model.GridSession, error) {
+	var sessions []model.GridSession
+	nextCursor := ""
+	for {
+		var responseData sessionListResponse
+		params := map[string]string{"team_id": teamId, "limit": "1000"}
+		if err := getAdminPage(constants.SlackAdminSessionListAPIURL, token, nextCursor, params, &responseData); err != nil {
+			return sessions, err
+		}
+		sessions = append(sessions, responseData.ActiveSessions...)
+		nextCursor = responseData.next()
+		if nextCursor == "" {
+			return sessions, nil
+		}
+	}
+}
+
+// enrichSession adds the country, user status and expiry of a session and flags it for review
+func (inv *sessionInventory) enrichSession(s *model.GridSession, teamId string, now int64, countries map[string]string, active map[string]bool, settings map[string]sessionSettings) error {
+	s.IP = sessionIP(*s)
+	s.Country = countries[s.IP]
+	s.CountryUnknown = s.Country == ""
+	s.UnexpectedCountry = len(inv.allowedCountries) > 0 && s.Country != "" && !inv.allowedCountries[s.Country]
+
+	isActive, known := active[s.UserID]
+	s.UserActive = isActive
+	s.DeactivatedUser = known && !isActive
+
+	key := teamId + "/" + strconv.FormatInt(s.SessionID, 10)
+	found, err := inv.firstSeen.Get(key, &s.FirstSeen)
+	if err != nil {
+		return err
+	}
+	if !found {
+		s.FirstSeen = now
+		if err = inv.firstSeen.Set(key, now); err != nil {
+			return err
+		}
+	}
+	if userSettings, ok := settings[s.UserID]; ok {
+		s.DesktopAppBrowserQuit = userSettings.desktopAppBrowserQuit
+		s.SessionDuration = userSettings.duration
+		if userSettings.duration > 0 {
+			s.ExpiresBy = s.FirstSeen + userSettings.duration
+		}
+	}
+	if s.UnexpectedCountry || s.DeactivatedUser {
+		slog.Warn("Active session needs review", "team", teamId, "user", s.UserID, "country", s.Country, "deactivatedUser", s.DeactivatedUser)
+	}
+	return nil
+}
+
+func (inv *sessionInventory) listSessions(token string, teamId string, teamName string, page func(records []interface{}) error) error {
+	sessions, err := inv.getSessions(token, teamId)
+	if err != nil {
+		return err
+	}
+	ips := make(map[string]bool)
+	for _, s := range sessions {
+		if ip := sessionIP(s); ip != "" {
+			ips[ip] = true
+		}
+	}
+	countries := getCountries(token, teamId, ips)
+	if len(countries) < len(ips) {
+		slog.Warn("Some session IPs have no login in team.accessLogs, their sessions are exported with country_unknown", "team", teamId, "ips", len(ips), "unknown", len(ips)-len(countries))
+	}
+	active, err := getActiveUsers(token, teamId)
+	if err != nil {
+		slog.Warn("Not able to read the status of users, sessions of deactivated users are not flagged", "error", err)
+	}
+	settings := map[string]sessionSettings{}
+	if inv.getSettings {
+		var users []string
+		seen := make(map[string]bool)
+		for _, s := range sessions {
+			if !seen[s.UserID] {
+				seen[s.UserID] = true
+				users = append(users, s.UserID)
+			}
+		}
+		settings = getSessionSettings(token, users)
+	}
+
+	now := time.Now().Unix()
+	current := make(map[string]bool)
+	records := make([]interface{}, 0, len(sessions))
+	for _, s := range sessions {
+		s.TeamName = teamName
+		if err = inv.enrichSession(&s, teamId, now, countries, active, settings); err != nil {
+			return err
+		}
+		current[teamId+"/"+strconv.FormatInt(s.SessionID, 10)] = true
+		records = append(records, s)
+	}
+	if err = page(records); err != nil {
+		return err
+	}
+	// Forget sessions which ended
+	for _, key := range inv.firstSeen.Keys(teamId + "/") {
+		if !current[key] {
+			inv.firstSeen.Delete(key)
+		}
+	}
+	return inv.firstSeen.Save()
+}
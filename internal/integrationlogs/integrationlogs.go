@@ -0,0 +1,229 @@
+package integrationlogs
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sort"
+	"time"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"slackLogs/internal/common"
+	"slackLogs/internal/logclient"
+	"slackLogs/internal/model"
+	"slackLogs/internal/constants"
+	"slackLogs/internal/state"
+)
+
+var (
+	totalLogsSize = 0
+	logtype       = "IntegrationLog"
+	logCount      = 0 // This variable helps to track number of logs exported in each request
+	pageSize      = 100
+)
+
+var logs = []logclient.Logs{}
+
+type IntegrationLogsHandler struct {
+	Client          *logclient.LogClient
+	PollingInterval time.Duration
+	checkpoints     *state.Store // team id -> date of the newest exported entry, team id/entries -> keys of the entries of that second
+}
+
+func NewIntegrationLogsHandler(client *logclient.LogClient, pollingInterval time.Duration) (*IntegrationLogsHandler, error) {
+	checkpoints, err := state.Open("integrationLogs")
+	if err != nil {
+		return nil, err
+	}
+	return &IntegrationLogsHandler{Client: client, PollingInterval: pollingInterval, checkpoints: checkpoints}, nil
+}
+
+// teamIntegrationLogsResponse contains slack API successful response
+// https://api.slack.com/methods/team.integrationLogs#examples
+type teamIntegrationLogsResponse struct {
+	Ok     bool                   `json:"ok"`
+	Logs   []model.IntegrationLog `json:"logs"`
+	Paging struct {
+		Count int `json:"count"`
+		Total int `json:"total"`
+		Page  int `json:"page"`
+		Pages int `json:"pages"`
+	} `json:"paging"`
+	ReqError string                 `json:"error"`
+	Random   map[string]interface{} `json:"-"`
+}
+
+func getSlackIntegrationLogs(c *common.SlackClient, teamId string, page int) (teamIntegrationLogsResponse, error) {
+	params := map[string]string{
+		"team_id": teamId,
+		"count":   strconv.Itoa(pageSize),
+		"page":    strconv.Itoa(page),
+	}
+	var responseData teamIntegrationLogsResponse
+	errSlack := c.SendRequest(common.WaitAndRetry, &responseData, params)
+	if errSlack != nil {
+		return responseData, errSlack
+	}
+	if !responseData.Ok {
+		return responseData, fmt.Errorf("Slack API error %v", responseData.ReqError)
+	}
+	return responseData, nil
+}
+
+// entryKey identifies an entry within a second, team.integrationLogs has no entry ids
+func entryKey(l model.IntegrationLog) string {
+	return strings.Join([]string{l.ServiceID, l.AppID, l.UserID, l.ChangeType, l.Channel, l.Scope}, "/")
+}
+
+// integrationCheckpoint is the date of the newest exported entry and the keys of the entries exported
+// with that date. Dates have a resolution of a second, so entries of the checkpoint second which were
+// not exported yet are told apart by their key.
+type integrationCheckpoint struct {
+	Date    int64
+	Entries map[string]bool
+}
+
+// add moves the checkpoint to the date of an exported entry
+func (cp *integrationCheckpoint) add(date int64, key string) {
+	if date > cp.Date {
+		cp.Date = date
+		cp.Entries = make(map[string]bool)
+	}
+	if date == cp.Date {
+		cp.Entries[key] = true
+	}
+}
+
+// transformIntegrationLogs adds entries which are not older than the checkpoint and were not exported
+// yet, and reports whether an older entry was reached. Entries are returned newest first, so older pages
+// need not be fetched. newest is moved to the exported entries.
+func transformIntegrationLogs(client *logclient.LogClient, integrationLogs []model.IntegrationLog, teamName string, checkpoint integrationCheckpoint, newest *integrationCheckpoint) (bool, error) {
+	ts := time.Now().Unix()
+	reachedCheckpoint := false
+	for _, l := range integrationLogs {
+		date, err := strconv.ParseInt(l.Date, 10, 64)
+		if err != nil {
+			return reachedCheckpoint, fmt.Errorf("invalid integration log date %s: %v", l.Date, err)
+		}
+		key := entryKey(l)
+		if date < checkpoint.Date {
+			reachedCheckpoint = true
+			continue
+		}
+		if date == checkpoint.Date && checkpoint.Entries[key] {
+			continue
+		}
+		newest.add(date, key)
+		l.TeamName = teamName
+		data, errJson := json.Marshal(l)
+		if errJson != nil {
+			return reachedCheckpoint, errJson
+		}
+		lm, keep := client.NewLogs(logtype, ts, data)
+		if !keep {
+			continue
+		}
+		totalLogsSize = totalLogsSize + len(lm.Message)
+		logCount = logCount + 1
+		logs = append(logs, lm)
+	}
+	return reachedCheckpoint, nil
+}
+
+// flushLogs exports the buffered logs, the buffer is emptied also when the export fails
+func (il *IntegrationLogsHandler) flushLogs() error {
+	var err error
+	if len(logs) > 0 {
+		err = il.Client.Flush(logtype, logs)
+	}
+	logs = []logclient.Logs{}
+	totalLogsSize = 0
+	logCount = 0
+	return err
+}
+
+func (il *IntegrationLogsHandler) ResetLogs() {
+	if err := il.flushLogs(); err != nil {
+		slog.Error("Not able to export integration logs", "error", err)
+	}
+}
+
+func (il *IntegrationLogsHandler) getCheckpoint(teamId string) (integrationCheckpoint, error) {
+	checkpoint := integrationCheckpoint{Entries: make(map[string]bool)}
+	found, err := il.checkpoints.Get(teamId, &checkpoint.Date)
+	if err != nil {
+		return checkpoint, err
+	}
+	if !found {
+		// First run, collect the last polling interval like the other collectors
+		checkpoint.Date = time.Now().Add(-(il.PollingInterval)).Unix()
+		return checkpoint, nil
+	}
+	var entries []string
+	if _, err = il.checkpoints.Get(teamId+"/entries", &entries); err != nil {
+		return checkpoint, err
+	}
+	for _, key := range entries {
+		checkpoint.Entries[key] = true
+	}
+	return checkpoint, nil
+}
+
+func (il *IntegrationLogsHandler) setCheckpoint(teamId string, checkpoint integrationCheckpoint) error {
+	entries := make([]string, 0, len(checkpoint.Entries))
+	for key := range checkpoint.Entries {
+		entries = append(entries, key)
+	}
+	sort.Strings(entries)
+	if err := il.checkpoints.Set(teamId, checkpoint.Date); err != nil {
+		return err
+	}
+	return il.checkpoints.Set(teamId+"/entries", entries)
+}
+
+func (il *IntegrationLogsHandler) Collect(token string, teamId string, teamName string) error {
+	logCount = 0
+	checkpoint, err := il.getCheckpoint(teamId)
+	if err != nil {
+		return err
+	}
+	slog.Info("Collecting integration logs", "team", teamName, "since", checkpoint.Date)
+	newest := integrationCheckpoint{Date: checkpoint.Date, Entries: make(map[string]bool)}
+	for key := range checkpoint.Entries {
+		newest.Entries[key] = true
+	}
+	for page := 1; ; page++ {
+		c := common.NewSlackClient(constants.SlackIntegrationLogsAPIURL, token, "")
+		// Get integration logs
+		response, err := getSlackIntegrationLogs(c, teamId, page)
+		if err != nil {
+			return err
+		}
+		// Filter required fields and add timestamp to each log
+		reachedCheckpoint, err := transformIntegrationLogs(il.Client, response.Logs, teamName, checkpoint, &newest)
+		if err != nil {
+			return err
+		}
+		// Check total collected logs size and maximum allowed logs size in a single request
+		if totalLogsSize >= constants.MaxAllowed {
+			if err = il.flushLogs(); err != nil {
+				slog.Error("Not able to export integration logs, they are collected again in the next iteration", "team", teamName, "error", err)
+				return nil
+			}
+		}
+		if reachedCheckpoint || page >= response.Paging.Pages {
+			slog.Debug("Collected integrationLogs since the last checkpoint")
+			break
+		}
+	}
+	// Flush rest of the logs, the checkpoint moves only when all of them were exported
+	if err = il.flushLogs(); err != nil {
+		slog.Error("Not able to export integration logs, they are collected again in the next iteration", "team", teamName, "error", err)
+		return nil
+	}
+	if err = il.setCheckpoint(teamId, newest); err != nil {
+		return err
+	}
+	return il.checkpoints.Save()
+}
@@ -11,8 +11,10 @@ import (
 	"context"
 	"io/ioutil"
 	"errors"
+	"strings"
 
 	"slackLogs/internal/args"
+	"slackLogs/internal/logging"
 )
 
 type NRResponce struct {
@@ -46,16 +48,89 @@ type CommonAttr struct {
 type Logs struct {
 	Timestamp int64  `json:"timestamp"`
 	Message   string `json:"message"`
+	processed bool   // The processors ran on the message already
+}
+
+// Processor changes a record, the JSON object of a log message, before it is exported.
+// Returning false drops the record.
+type Processor interface {
+	Process(logtype string, record map[string]interface{}) bool
 }
 
 type LogClient struct {
 	//logMessage *LogSet
-	mux        sync.Mutex
+	mux        *sync.Mutex
 	msgSize    int
+	attributes map[string]string // Added to the common attributes of every log set
+	processors []Processor       // Run in order on every record
 }
 
 func NewLogClient() *LogClient {
-	return &LogClient{msgSize: 0}
+	return &LogClient{mux: &sync.Mutex{}, msgSize: 0}
+}
+
+// WithAttributes returns a client exporting to the same sink which adds attrs to every log set,
+// e.g. the workspace the logs were collected from
+func (c *LogClient) WithAttributes(attrs map[string]string) *LogClient {
+	merged := make(map[string]string, len(c.attributes)+len(attrs))
+	for k, v := range c.attributes {
+		merged[k] = v
+	}
+	for k, v := range attrs {
+		merged[k] = v
+	}
+	return &LogClient{mux: c.mux, attributes: merged, processors: c.processors}
+}
+
+// Use adds a processor to the records of every log set, clients created afterwards with
+// WithAttributes use it as well
+func (c *LogClient) Use(p Processor) {
+	c.processors = append(c.processors, p)
+}
+
+// NewLogs creates the log of a record and runs the processors on it. Collectors add the size of the
+// returned message to their batch, so batches are cut on the size of what is exported and dropped
+// records don't count. It returns false when a processor dropped the record.
+func (c *LogClient) NewLogs(logtype string, timestamp int64, data []byte) (Logs, bool) {
+	return c.processLog(logtype, Logs{Timestamp: timestamp, Message: string(data)})
+}
+
+func (c *LogClient) processLog(logtype string, l Logs) (Logs, bool) {
+	if len(c.processors) == 0 || l.processed {
+		l.processed = true
+		return l, true
+	}
+	record := make(map[string]interface{})
+	decoder := json.NewDecoder(strings.NewReader(l.Message))
+	// Keep large numbers, e.g. ids, as they are
+	decoder.UseNumber()
+	if err := decoder.Decode(&record); err != nil {
+		// Not a JSON object, nothing to process
+		l.processed = true
+		return l, true
+	}
+	for _, p := range c.processors {
+		if !p.Process(logtype, record) {
+			return l, false
+		}
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		slog.Error("Error marshaling processed record", "logtype", logtype, "error", err)
+		return l, false
+	}
+	return Logs{Timestamp: l.Timestamp, Message: string(data), processed: true}, true
+}
+
+// processLogs runs the processors on the records which were not created with NewLogs, dropped records are left out
+func (c *LogClient) processLogs(logtype string, logs []Logs) []Logs {
+	processed := make([]Logs, 0, len(logs))
+	for _, l := range logs {
+		if l, keep := c.processLog(logtype, l); keep {
+			processed = append(processed, l)
+		}
+	}
+	return processed
 }
 
 func (c *LogClient) Flush(logtype string, logs []Logs) error {
@@ -66,6 +141,11 @@ func (c *LogClient) Flush(logtype string, logs []Logs) error {
 		// No data , no error
 		return nil
 	}
+	logs = c.processLogs(logtype, logs)
+	if len(logs) == 0 {
+		slog.Info("All logs were dropped by processors for the", "logtype", logtype)
+		return nil
+	}
 	logCount := len(logs)
 
 	ls := LogSet{
@@ -77,6 +157,11 @@ func (c *LogClient) Flush(logtype string, logs []Logs) error {
 		},
 		Logs: make([]Logs, len(logs)),
 	}
+	for k, v := range c.attributes {
+		if _, reserved := ls.Common.Attributes[k]; !reserved {
+			ls.Common.Attributes[k] = v
+		}
+	}
 	c.mux.Lock()
         ls.Logs = logs
 	err := c.ExportLogsToEndpoint(&ls)
@@ -94,9 +179,9 @@ func (c *LogClient) Flush(logtype string, logs []Logs) error {
 func handleErrorResponse(statusCode int) {
 	switch statusCode {
 	case http.StatusRequestEntityTooLarge:
-		slog.Debug("There was an error when communicating to New Relic One. The message was too big. %v", statusCode)
+		slog.Debug("There was an error when communicating to New Relic One. The message was too big.", "statusCode", statusCode)
 	default :
-		slog.Debug("There was an error when communicating to New Relic One. %v", statusCode)
+		slog.Debug("There was an error when communicating to New Relic One.", "statusCode", statusCode)
 	}
 }
 
@@ -107,9 +192,11 @@ func (c *LogClient) ExportLogsToEndpoint(msg *LogSet) error {
 	// Marshal the body
 	body, err := json.Marshal([]LogSet{*msg})
 	if err != nil {
-		slog.Error("Error marshaling json: %s", "error", err)
+		slog.Error("Error marshaling json", "error", err)
+	}
+	if logging.TracePayloads() {
+		slog.Debug("Marshaled", "payload", string(body))
 	}
-	slog.Debug("Marshaled", "body", string(body))
 	
 
 	// Compress log data
@@ -117,14 +204,14 @@ func (c *LogClient) ExportLogsToEndpoint(msg *LogSet) error {
 	gzipWriter := gzip.NewWriter(&compressedLogData)
 	_, errCompression := gzipWriter.Write(body)
 	if errCompression != nil {
-		slog.Debug("Error compressing log data:", errCompression)
+		slog.Debug("Error compressing log data", "error", errCompression)
 		return errCompression
 	}
 	gzipWriter.Close()
 
 	req, errRequest := http.NewRequestWithContext(ctx, "POST", args.GetNRLogEndpoint(), &compressedLogData)
 	if errRequest != nil {
-		slog.Debug("There was an error when communicating to New Relic One: %v.", errRequest)
+		slog.Debug("There was an error when communicating to New Relic One", "error", errRequest)
 		return errRequest
 	}
 
@@ -135,13 +222,13 @@ func (c *LogClient) ExportLogsToEndpoint(msg *LogSet) error {
 	resp, err := HttpClient.Do(req)
 
 	if err != nil {
-		slog.Info("There was an error when creating a new client in New Relic One: %v.", err)
+		slog.Info("There was an error when creating a new client in New Relic One", "error", err)
 		return err
 	} else {
 		defer resp.Body.Close()
 		body, errResponse := ioutil.ReadAll(resp.Body)
 		if errResponse != nil {
-			slog.Debug("There was an error when communicating to New Relic One: %v.", errResponse)
+			slog.Debug("There was an error when communicating to New Relic One", "error", errResponse)
 			return errResponse
 		} else {
 			if resp.StatusCode >= 300 {
@@ -151,7 +238,7 @@ func (c *LogClient) ExportLogsToEndpoint(msg *LogSet) error {
 				var nr NRResponce
 				errJson := json.Unmarshal(body, &nr)
 				if errJson != nil {
-					slog.Info("There was an error when parsing the response from New Relic One: %v.", errJson)
+					slog.Info("There was an error when parsing the response from New Relic One", "error", errJson)
 					return errJson
 				}
 				slog.Debug("Successfully pushed logs to NR", "Req Id",  nr.RequestId)
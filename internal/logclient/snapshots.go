@@ -0,0 +1,92 @@
+package logclient
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sort"
+
+	"slackLogs/internal/constants"
+	"slackLogs/internal/state"
+)
+
+// SnapshotBatch buffers the logs of a snapshot collector, e.g. channel members, per logtype together
+// with the snapshots they were diffed against. Snapshots are stored only after their logs were
+// exported, so the changes of a failed export are found again in the next iteration.
+type SnapshotBatch struct {
+	client    *LogClient
+	snapshots *state.Store // snapshot key -> sorted values of the previous snapshot
+	logs      map[string][]Logs
+	pending   map[string][]string // snapshot key -> snapshot of the buffered logs
+	size      int
+}
+
+func NewSnapshotBatch(client *LogClient, snapshots *state.Store) *SnapshotBatch {
+	return &SnapshotBatch{client: client, snapshots: snapshots, logs: make(map[string][]Logs), pending: make(map[string][]string)}
+}
+
+// Previous returns the stored snapshot of key and reports whether there is one
+func (b *SnapshotBatch) Previous(key string) ([]string, bool, error) {
+	var previous []string
+	found, err := b.snapshots.Get(key, &previous)
+	return previous, found, err
+}
+
+// Add buffers v as a log of logtype, records dropped by a processor are left out
+func (b *SnapshotBatch) Add(logtype string, timestamp int64, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	lm, keep := b.client.NewLogs(logtype, timestamp, data)
+	if !keep {
+		return nil
+	}
+	b.size = b.size + len(lm.Message)
+	b.logs[logtype] = append(b.logs[logtype], lm)
+	return nil
+}
+
+// SetSnapshot sets the snapshot of key, it is stored when the buffered logs are exported
+func (b *SnapshotBatch) SetSnapshot(key string, values []string) {
+	b.pending[key] = values
+}
+
+// Full reports whether the buffered logs reached the maximum size of a single request
+func (b *SnapshotBatch) Full() bool {
+	return b.size >= constants.MaxAllowed
+}
+
+// Export exports the buffered logs and then stores their snapshots. A failed export is logged and its
+// snapshots are dropped, only errors of the snapshot store are returned.
+func (b *SnapshotBatch) Export() error {
+	logtypes := make([]string, 0, len(b.logs))
+	for lt := range b.logs {
+		logtypes = append(logtypes, lt)
+	}
+	sort.Strings(logtypes)
+	var errFlush error
+	for _, lt := range logtypes {
+		if err := b.client.Flush(lt, b.logs[lt]); err != nil && errFlush == nil {
+			errFlush = err
+		}
+	}
+	pending := b.pending
+	b.logs = make(map[string][]Logs)
+	b.pending = make(map[string][]string)
+	b.size = 0
+	if errFlush != nil {
+		slog.Error("Not able to export snapshot logs, they are collected again in the next iteration", "logtypes", logtypes, "error", errFlush)
+		return nil
+	}
+	for key, values := range pending {
+		if err := b.snapshots.Set(key, values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Save persists the stored snapshots
+func (b *SnapshotBatch) Save() error {
+	return b.snapshots.Save()
+}
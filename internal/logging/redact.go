@@ -0,0 +1,179 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const redacted = "[REDACTED]"
+
+var (
+	// Slack tokens (xoxb-, xoxp-, xoxe-, xapp- ...) and New Relic keys
+	tokenPattern = regexp.MustCompile(`\b(xox[a-z](\.xox[a-z])?-[A-Za-z0-9-]+|xapp-[A-Za-z0-9-]+|NRAK-[A-Z0-9]+|[a-f0-9]{36}NRAL)\b`)
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+
+	// Attributes whose whole value is a credential
+	secretKeys = []string{"token", "key", "secret", "password", "authorization", "cookie"}
+	// Attributes holding Slack API responses, exported payloads or message content
+	payloadKeys = []string{"body", "payload", "text", "message", "messages", "blocks"}
+)
+
+// RedactingHandler wraps a slog.Handler and masks credentials, email addresses and
+// message content before records are written, so debug logging is safe in production.
+// With tracePayloads, API responses and exported payloads are logged as they are,
+// but credentials are still masked.
+type RedactingHandler struct {
+	handler       slog.Handler
+	tracePayloads bool
+}
+
+func NewRedactingHandler(h slog.Handler, tracePayloads bool) *RedactingHandler {
+	return &RedactingHandler{handler: h, tracePayloads: tracePayloads}
+}
+
+// TracePayloads reports whether the default logger logs API responses and exported payloads, it is
+// the one switch for payload tracing
+func TracePayloads() bool {
+	h, ok := slog.Default().Handler().(*RedactingHandler)
+	return ok && h.tracePayloads
+}
+
+func (h *RedactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *RedactingHandler) Handle(ctx context.Context, r slog.Record) error {
+	redactedRecord := slog.NewRecord(r.Time, r.Level, h.scrub(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		redactedRecord.AddAttrs(h.redactAttr(a))
+		return true
+	})
+	return h.handler.Handle(ctx, redactedRecord)
+}
+
+func (h *RedactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redactedAttrs := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		redactedAttrs = append(redactedAttrs, h.redactAttr(a))
+	}
+	return &RedactingHandler{handler: h.handler.WithAttrs(redactedAttrs), tracePayloads: h.tracePayloads}
+}
+
+func (h *RedactingHandler) WithGroup(name string) slog.Handler {
+	return &RedactingHandler{handler: h.handler.WithGroup(name), tracePayloads: h.tracePayloads}
+}
+
+// lastSegment returns the last word of a key split on _, -, . and camelCase, e.g. "token" for
+// "slackToken" or "ingest_token", so "keyCount" and "contextTeamId" don't match "key" and "text"
+func lastSegment(key string) string {
+	key = strings.TrimRight(key, "_-.")
+	if i := strings.LastIndexAny(key, "_-."); i >= 0 {
+		key = key[i+1:]
+	}
+	runes := []rune(key)
+	for i := len(runes) - 1; i > 0; i-- {
+		if unicode.IsUpper(runes[i]) && (unicode.IsLower(runes[i-1]) || i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+			return strings.ToLower(string(runes[i:]))
+		}
+	}
+	return strings.ToLower(key)
+}
+
+func matchesKey(key string, keys []string) bool {
+	segment := lastSegment(key)
+	for _, k := range keys {
+		if segment == k {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *RedactingHandler) redactAttr(a slog.Attr) slog.Attr {
+	value := a.Value.Resolve()
+	if value.Kind() == slog.KindGroup {
+		group := value.Group()
+		redactedGroup := make([]any, 0, len(group))
+		for _, ga := range group {
+			redactedGroup = append(redactedGroup, h.redactAttr(ga))
+		}
+		return slog.Group(a.Key, redactedGroup...)
+	}
+	if matchesKey(a.Key, secretKeys) {
+		return slog.String(a.Key, redacted)
+	}
+	var s string
+	switch value.Kind() {
+	case slog.KindString:
+		s = value.String()
+	case slog.KindAny:
+		switch v := value.Any().(type) {
+		case error:
+			// Errors may embed API responses, scrub their text form
+			s = v.Error()
+		case fmt.Stringer:
+			s = v.String()
+		case []byte:
+			s = string(v)
+		default:
+			// Maps and structs, e.g. decoded API responses, get the key rules applied to their fields
+			data, err := json.Marshal(v)
+			if err != nil {
+				s = fmt.Sprintf("%+v", v)
+				break
+			}
+			var decoded interface{}
+			if err = json.Unmarshal(data, &decoded); err != nil {
+				s = string(data)
+				break
+			}
+			if !h.tracePayloads && matchesKey(a.Key, payloadKeys) {
+				return slog.String(a.Key, fmt.Sprintf("[REDACTED %d bytes, enable global.tracePayloads to log]", len(data)))
+			}
+			return slog.Any(a.Key, h.redactValue(decoded))
+		}
+	default:
+		return slog.Attr{Key: a.Key, Value: value}
+	}
+	if !h.tracePayloads && matchesKey(a.Key, payloadKeys) {
+		return slog.String(a.Key, fmt.Sprintf("[REDACTED %d bytes, enable global.tracePayloads to log]", len(s)))
+	}
+	return slog.String(a.Key, h.scrub(s))
+}
+
+// redactValue applies the key rules to the fields of a decoded JSON value and scrubs its strings
+func (h *RedactingHandler) redactValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if matchesKey(key, secretKeys) || !h.tracePayloads && matchesKey(key, payloadKeys) {
+				v[key] = redacted
+			} else {
+				v[key] = h.redactValue(field)
+			}
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = h.redactValue(item)
+		}
+		return v
+	case string:
+		return h.scrub(v)
+	}
+	return v
+}
+
+// scrub masks credentials and, unless payload tracing is enabled, email addresses in s
+func (h *RedactingHandler) scrub(s string) string {
+	s = tokenPattern.ReplaceAllString(s, redacted)
+	if !h.tracePayloads {
+		s = emailPattern.ReplaceAllString(s, redacted)
+	}
+	return s
+}
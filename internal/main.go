@@ -6,126 +6,389 @@ import (
 	"slackLogs/internal/logclient"
 	"slackLogs/internal/userlogs"
 	"slackLogs/internal/channellogs"
+	"slackLogs/internal/blockkit"
+	"slackLogs/internal/channelregistry"
+	"slackLogs/internal/directory"
+	"slackLogs/internal/enrichment"
 	"slackLogs/internal/accesslogs"
 	"slackLogs/internal/conversationlogs"
-	"slackLogs/internal/teamslist"
 	"slackLogs/internal/auditlogs"
+	"slackLogs/internal/integrationlogs"
+	"slackLogs/internal/membershiplogs"
+	"slackLogs/internal/projection"
+	"slackLogs/internal/redaction"
+	"slackLogs/internal/filelogs"
+	"slackLogs/internal/usergrouplogs"
+	"slackLogs/internal/gridlogs"
+	"slackLogs/internal/auth"
+	"slackLogs/internal/events"
+	"slackLogs/internal/eventsapi"
+	"slackLogs/internal/constants"
+	"slackLogs/internal/scheduler"
+	"slackLogs/internal/socketmode"
+	"slackLogs/internal/state"
+	"slackLogs/internal/workspaces"
 
-	"sync"
 	"time"
 	"os"
+	"fmt"
 	"log/slog"
 	"log"
 )
 
 var logClient *logclient.LogClient
-var slackToken string
-var teamsInfo = make(map[string]string)
+var collectorScheduler = scheduler.NewScheduler()
 var defaultChannelLogsInterval = 24 * time.Hour
+// Channels discovered by the ChannelDetails collector, consumed by the collectors which work per channel
+var channelRegistry = channelregistry.New()
+// Resolves user and channel ids in exported logs, nil when enrichment is disabled
+var userDirectory *directory.Directory
+var defaultUserLogsInterval = 24 * time.Hour
 
-func updateSlackToken() {
-        val, ok := os.LookupEnv("SLACK_ACCESS_TOKEN")
-        if !ok {
-                log.Fatalln("**** Please set SLACK_ACCESS_TOKEN. ***** ")
-        }
-	slackToken = val
-}
-
-func collectAndExportLogsToNR(c common.CollectLogs,  wg *sync.WaitGroup, logType string, iteration int) {
-	defer wg.Done()
-	for id, name := range teamsInfo {
-		err := c.Collect(slackToken, id, name)
+func collectAndExportLogsToNR(w *workspaces.Workspace, c common.CollectLogs, logType string, iteration int) {
+	for id, name := range w.TeamsInfo {
+		err := c.Collect(w.Token(), id, name)
 		if err != nil {
 			// Log the error
-			log.Fatalln("Received an error in collecting/exporting logType: ", logType, err)
+			log.Fatalln("Received an error in collecting/exporting logType: ", logType, "workspace: ", w.Name, err)
 		}
 	}
-	slog.Info("Done, Collected logs", "logType", logType, "iteration", iteration)
+	slog.Info("Done, Collected logs", "logType", logType, "workspace", w.Name, "iteration", iteration)
+
+}
+
+func CollectLogs(w *workspaces.Workspace, interval time.Duration, c common.CollectLogs, logType string) {
+	slog.Info("Initiating new polling iteration for", "logType", logType, "workspace", w.Name)
+	collectorScheduler.Schedule(logType, w.Name, interval, func(iteration int) {
+		collectAndExportLogsToNR(w, c, logType, iteration)
+	})
+}
 
+// CollectOrgLogs schedules a collector which runs once per Grid org instead of once per team
+func CollectOrgLogs(w *workspaces.Workspace, interval time.Duration, c common.CollectLogs, logType string) {
+	slog.Info("Initiating new polling iteration for", "logType", logType, "workspace", w.Name)
+	collectorScheduler.Schedule(logType, w.Name, interval, func(iteration int) {
+		err := c.Collect(w.Token(), w.EnterpriseID, w.EnterpriseName)
+		if err != nil {
+			log.Fatalln("Received an error in collecting/exporting logType: ", logType, "workspace: ", w.Name, err)
+		}
+		slog.Info("Done, Collected logs", "logType", logType, "workspace", w.Name, "iteration", iteration)
+	})
+}
+
+// getChannelsBeforeCollecting starts a collector which needs the channel list once the channels of all teams of the workspace are known
+func getChannelsBeforeCollecting(w *workspaces.Workspace, interval time.Duration, c common.CollectLogs, logType string) {
+	teamIds := make([]string, 0, len(w.TeamsInfo))
+	for id := range w.TeamsInfo {
+		teamIds = append(teamIds, id)
+	}
+	channelRegistry.Wait(teamIds)
+	CollectLogs(w, interval, c, logType)
 }
 
-func CollectLogs(interval time.Duration, c common.CollectLogs, logType string) {
-	var wg sync.WaitGroup
-	iteration := 1
-	slog.Info("Initiating new polling iteration for", "logType", logType)
-	wg.Add(1)
-	go collectAndExportLogsToNR(c, &wg, logType, iteration)
-	for {
-		select {
-		case <-time.After(interval):
-			iteration++
-			slog.Info("Starting polling iteration for", "logType", logType, "iteration", iteration)
-			wg.Add(1)
-			go collectAndExportLogsToNR(c, &wg, logType, iteration)
-		}
-	}
-	wg.Wait()
+// Collectors in the order they are reported by check-scopes
+var allCollectors = []string{
+	constants.UserLogsCollector,
+	constants.ChannelDetailsCollector,
+	constants.AccessLogsCollector,
+	constants.AuditLogsCollector,
+	constants.ConversationLogsCollector,
+	constants.IntegrationLogsCollector,
+	constants.ChannelMembershipCollector,
+	constants.FileLogsCollector,
+	constants.UserGroupsCollector,
+	constants.GridTeamsCollector,
+	constants.GridUsersCollector,
+	constants.GridConversationsCollector,
+	constants.GridAppsCollector,
+	constants.GridSessionsCollector,
 }
 
+// Enterprise Grid collectors, started only for org-level tokens
+var gridCollectors = []string{
+	constants.GridTeamsCollector,
+	constants.GridUsersCollector,
+	constants.GridConversationsCollector,
+	constants.GridAppsCollector,
+	constants.GridSessionsCollector,
+}
 
-func getChannelsBeforeCollectingConversations(interval time.Duration) {
-	if !(args.GetChannelDetailsEnabled()) {
-		go CollectLogs(defaultChannelLogsInterval, channellogs.NewChannelLogsHandler(logClient), "ChannelDetails")
+func newGridHandler(name string, client *logclient.LogClient) (common.CollectLogs, error) {
+	switch name {
+	case constants.GridTeamsCollector:
+		return gridlogs.NewTeamsHandler(client), nil
+	case constants.GridUsersCollector:
+		return gridlogs.NewUsersHandler(client), nil
+	case constants.GridConversationsCollector:
+		return gridlogs.NewConversationsHandler(client), nil
+	case constants.GridAppsCollector:
+		return gridlogs.NewAppsHandler(client), nil
+	default:
+		policy := args.GetSessionPolicy()
+		return gridlogs.NewSessionsHandler(client, policy.AllowedCountries, policy.GetSettings)
 	}
-	for {
-		if !(channellogs.GetChannelStatus()) {
-			time.Sleep(1 * time.Second)
+}
+
+func startGridCollectors(w *workspaces.Workspace) {
+	for _, name := range gridCollectors {
+		if !w.Enabled(name) {
+			continue
+		}
+		if !w.IsEnterpriseInstall {
+			slog.Warn("Skipping Grid collector, it needs an org-level token of an Enterprise Grid org", "workspace", w.Name, "collector", name)
+			continue
+		}
+		slog.Info("Grid collector enabled: Initiating Slack API logs collection for", "collector", name, "workspace", w.Name)
+		interval := w.Collectors[name].PollingInterval
+		handler, err := newGridHandler(name, w.LogClient)
+		if err != nil {
+			log.Fatalln("Not able to initialize ", name, ", err", err)
+		}
+		if name == constants.GridTeamsCollector {
+			CollectOrgLogs(w, interval, handler, name)
 		} else {
-			time.Sleep(1 * time.Second)
-			go CollectLogs(interval, conversationlogs.NewConversationLogsHandler(logClient), "ConversationLogs")
-			return
+			CollectLogs(w, interval, handler, name)
 		}
 	}
 }
 
-func main() {
-	updateSlackToken()
-	logClient = logclient.NewLogClient()
+func enabledCollectors(w *workspaces.Workspace) []string {
+	var collectors []string
+	for _, name := range allCollectors {
+		if w.Enabled(name) {
+			collectors = append(collectors, name)
+		}
+	}
+	return collectors
+}
 
-	// Collect team information
-	teamsList, err := teamslist.GetSlackTeamList(slackToken)
-        if err != nil {
-		log.Fatalln("Not able to fetch teams list with the provided token, err" , err)
-        }
-	if len(teamsList) > 0 {
-		for _, team := range teamsList {
-			teamsInfo[team.Id] = team.Name
+// preflightScopes warns about (and optionally disables) collectors whose scopes are not granted,
+// instead of letting them fail in the middle of a polling iteration
+func preflightScopes(w *workspaces.Workspace) {
+	if args.GetScopePreflight() == args.ScopePreflightOff {
+		return
+	}
+	report, err := auth.CheckScopes(w.Token(), enabledCollectors(w))
+	if err != nil {
+		log.Fatalln("Not able to verify the provided token with auth.test, workspace: ", w.Name, err)
+	}
+	if !report.Info.ScopesKnown {
+		slog.Warn("Slack did not return the granted scopes, skipping scope preflight", "workspace", w.Name)
+		return
+	}
+	for _, collector := range report.FailingCollectors() {
+		slog.Warn("Collector will fail, token is missing required scopes", "workspace", w.Name, "collector", collector, "missingScopes", report.Missing[collector])
+		if args.GetScopePreflight() == args.ScopePreflightDisable {
+			slog.Warn("Disabling collector because of missing scopes", "workspace", w.Name, "collector", collector)
+			w.DisableCollector(collector)
 		}
-	} else {
-		teamInfo, _ := teamslist.GetSlackTeamInfo(slackToken)
-		teamsInfo[teamInfo.Id] = teamInfo.Name
 	}
-	slog.Info("Starting Slack API logs collection for", "teamsInfo", teamsInfo)
+}
 
-	if args.GetUserLogsEnabled() {
-		slog.Info("UserLogs enabled: Initiating Slack API logs collection for UserLogs")
-		interval := args.GetUserLogsPollingInterval()
-		go CollectLogs(interval, userlogs.NewUserLogsHandler(logClient), "UserLogs")
+// checkScopes prints the scope status of every collector and returns the process exit code
+func checkScopes(ws []*workspaces.Workspace) int {
+	exitCode := 0
+	for _, w := range ws {
+		fmt.Println("Workspace:", w.Name)
+		report, err := auth.CheckScopes(w.Token(), allCollectors)
+		if err != nil {
+			fmt.Println("Not able to verify the provided token with auth.test:", err)
+			exitCode = 2
+			continue
+		}
+		fmt.Printf("Team: %s (%s), user: %s, enterprise install: %v\n", report.Info.Team, report.Info.TeamID, report.Info.User, report.Info.IsEnterpriseInstall)
+		if !report.Info.ScopesKnown {
+			fmt.Println("Slack did not return the granted scopes for this token")
+			exitCode = 2
+			continue
+		}
+		fmt.Println("Granted scopes:", report.Info.Scopes)
+		for _, name := range allCollectors {
+			status := "ok"
+			if missing, ok := report.Missing[name]; ok {
+				status = fmt.Sprintf("will fail, missing %v", missing)
+				if w.Enabled(name) && exitCode == 0 {
+					exitCode = 1
+				}
+			}
+			fmt.Printf("%-18s enabled=%-5v %s\n", name, w.Enabled(name), status)
+		}
 	}
+	return exitCode
+}
 
-	if args.GetChannelDetailsEnabled() {
-		slog.Info("ChannelDetails enabled: Initiating Slack API logs collection for ChannelDetails")
-		interval := args.GetChannelDetailsPollingInterval()
-		go CollectLogs(interval, channellogs.NewChannelLogsHandler(logClient), "ChannelDetails")
+func startCollectors(w *workspaces.Workspace) {
+	if w.Enabled(constants.UserLogsCollector) {
+		slog.Info("UserLogs enabled: Initiating Slack API logs collection for UserLogs", "workspace", w.Name)
+		interval := w.Collectors[constants.UserLogsCollector].PollingInterval
+		CollectLogs(w, interval, userlogs.NewUserLogsHandler(w.LogClient, userDirectory, true), constants.UserLogsCollector)
+	} else if userDirectory != nil {
+		// Enrichment needs the users even when UserLogs is disabled
+		CollectLogs(w, defaultUserLogsInterval, userlogs.NewUserLogsHandler(w.LogClient, userDirectory, false), constants.UserLogsCollector)
 	}
 
-	if  args.GetAccessLogsEnabled() {
-		slog.Info("AccessLogs enabled: Initiating Slack API logs collection for AccessLogs")
-		interval := args.GetAccessLogsPollingInterval()
-		go CollectLogs(interval, accesslogs.NewAccessLogsHandler(logClient), "AccessLogs")
+	startGridCollectors(w)
+
+	if w.Enabled(constants.UserGroupsCollector) {
+		slog.Info("UserGroups enabled: Initiating Slack API logs collection for UserGroups", "workspace", w.Name)
+		interval := w.Collectors[constants.UserGroupsCollector].PollingInterval
+		handler, err := usergrouplogs.NewUserGroupLogsHandler(w.LogClient)
+		if err != nil {
+			log.Fatalln("Not able to initialize UserGroups, err", err)
+		}
+		CollectLogs(w, interval, handler, constants.UserGroupsCollector)
+	}
+
+	if w.Enabled(constants.ChannelDetailsCollector) {
+		slog.Info("ChannelDetails enabled: Initiating Slack API logs collection for ChannelDetails", "workspace", w.Name)
+		interval := w.Collectors[constants.ChannelDetailsCollector].PollingInterval
+		CollectLogs(w, interval, channellogs.NewChannelLogsHandler(w.LogClient, channelRegistry, true, args.GetChannelTypes(), args.GetExcludeArchived()), constants.ChannelDetailsCollector)
+	}
+
+	if w.Enabled(constants.AccessLogsCollector) {
+		slog.Info("AccessLogs enabled: Initiating Slack API logs collection for AccessLogs", "workspace", w.Name)
+		interval := w.Collectors[constants.AccessLogsCollector].PollingInterval
+		CollectLogs(w, interval, accesslogs.NewAccessLogsHandler(w.LogClient, interval), constants.AccessLogsCollector)
+	}
+
+	if w.Enabled(constants.AuditLogsCollector) {
+		slog.Info("AuditLogs enabled: Initiating Slack API logs collection for AuditLogs", "workspace", w.Name)
+		interval := w.Collectors[constants.AuditLogsCollector].PollingInterval
+		CollectLogs(w, interval, auditlogs.NewAuditLogsHandler(w.LogClient, interval), constants.AuditLogsCollector)
 	}
 
-	if  args.GetAuditLogsEnabled() {
-		slog.Info("AuditLogs enabled: Initiating Slack API logs collection for AuditLogs")
-		interval := args.GetAuditLogsPollingInterval()
-		go CollectLogs(interval, auditlogs.NewAuditLogsHandler(logClient), "AuditLogs")
+	if w.Enabled(constants.IntegrationLogsCollector) {
+		slog.Info("IntegrationLogs enabled: Initiating Slack API logs collection for IntegrationLogs", "workspace", w.Name)
+		interval := w.Collectors[constants.IntegrationLogsCollector].PollingInterval
+		handler, err := integrationlogs.NewIntegrationLogsHandler(w.LogClient, interval)
+		if err != nil {
+			log.Fatalln("Not able to initialize IntegrationLogs, err", err)
+		}
+		CollectLogs(w, interval, handler, constants.IntegrationLogsCollector)
 	}
 
-	if  args.GetConversationLogsEnabled() {
-		slog.Info("ConversationLogs enabled: Initiating Slack API logs collection for ConversationLogs")
-		interval := args.GetConversationLogsPollingInterval()
-		getChannelsBeforeCollectingConversations(interval)
+	// Conversations and memberships are collected per channel and files and enriched logs get channel names,
+	// list the channels even when ChannelDetails is disabled
+	if !w.Enabled(constants.ChannelDetailsCollector) && (w.Enabled(constants.ConversationLogsCollector) || w.Enabled(constants.ChannelMembershipCollector) || w.Enabled(constants.FileLogsCollector) || userDirectory != nil) {
+		CollectLogs(w, defaultChannelLogsInterval, channellogs.NewChannelLogsHandler(w.LogClient, channelRegistry, false, args.GetChannelTypes(), args.GetExcludeArchived()), constants.ChannelDetailsCollector)
+	}
+
+	if w.Enabled(constants.ConversationLogsCollector) {
+		slog.Info("ConversationLogs enabled: Initiating Slack API logs collection for ConversationLogs", "workspace", w.Name)
+		interval := w.Collectors[constants.ConversationLogsCollector].PollingInterval
+		handler, err := conversationlogs.NewConversationLogsHandler(w.LogClient, channelRegistry, interval, args.GetConversationTypes(), args.GetConversationLogsFilter(), args.GetReconcileWindow(), args.GetThreadWindow(), args.GetAutoJoin())
+		if err != nil {
+			log.Fatalln("Not able to initialize ConversationLogs, err", err)
+		}
+		go getChannelsBeforeCollecting(w, interval, handler, constants.ConversationLogsCollector)
+	}
+
+	if w.Enabled(constants.ChannelMembershipCollector) {
+		slog.Info("ChannelMembership enabled: Initiating Slack API logs collection for ChannelMembership", "workspace", w.Name)
+		interval := w.Collectors[constants.ChannelMembershipCollector].PollingInterval
+		handler, err := membershiplogs.NewMembershipLogsHandler(w.LogClient, channelRegistry, args.GetChannelMembershipFilter())
+		if err != nil {
+			log.Fatalln("Not able to initialize ChannelMembership, err", err)
+		}
+		go getChannelsBeforeCollecting(w, interval, handler, constants.ChannelMembershipCollector)
+	}
+
+	if w.Enabled(constants.FileLogsCollector) {
+		slog.Info("FileLogs enabled: Initiating Slack API logs collection for FileLogs", "workspace", w.Name)
+		interval := w.Collectors[constants.FileLogsCollector].PollingInterval
+		handler, err := filelogs.NewFileLogsHandler(w.LogClient, channelRegistry, interval)
+		if err != nil {
+			log.Fatalln("Not able to initialize FileLogs, err", err)
+		}
+		go getChannelsBeforeCollecting(w, interval, handler, constants.FileLogsCollector)
+	}
+}
+
+// teamResolver attributes events to the workspace of their team
+func teamResolver(ws []*workspaces.Workspace) events.TeamResolver {
+	return func(teamId string) (*logclient.LogClient, string) {
+		for _, w := range ws {
+			if name, ok := w.TeamsInfo[teamId]; ok {
+				return w.LogClient, name
+			}
+		}
+		return logClient, ""
+	}
+}
+
+// startEventSources starts the Events API receiver and/or the Socket Mode client, both feed one event processor
+func startEventSources(ws []*workspaces.Workspace) {
+	config := args.GetEvents()
+	processor := events.NewProcessor(teamResolver(ws), config.FlushInterval)
+	if config.Enabled {
+		slog.Info("Events enabled: Initiating Slack Events API receiver")
+		receiver := eventsapi.NewReceiver(processor, config.SigningSecret)
+		go func() {
+			err := receiver.ListenAndServe(config.ListenAddress, config.Path)
+			log.Fatalln("Slack Events API receiver stopped, err", err)
+		}()
+	}
+	if args.GetSocketMode().Enabled {
+		slog.Info("SocketMode enabled: Initiating Slack Socket Mode client")
+		socketMode := args.GetSocketMode()
+		go socketmode.NewClient(socketMode.AppToken, socketMode.ConnectionsOpenURL, processor).Run()
+	}
+}
+
+func main() {
+	common.SetRateLimit(args.GetRateLimitPerMinute())
+	state.SetDirectory(args.GetStateDirectory())
+	auth.RequireChannelTypes(args.GetChannelTypes(), args.GetConversationTypes(), args.GetMembershipTypes())
+	if args.GetAutoJoin() {
+		auth.RequireChannelJoin()
+	}
+	logClient = logclient.NewLogClient()
+	if config := args.GetEnrichment(); config.Enabled {
+		userDirectory = directory.New(channelRegistry)
+		logClient.Use(enrichment.NewEnricher(userDirectory, config.RewriteMentions, config.IncludeEmail))
+	}
+	if config := args.GetRendering(); config.Enabled {
+		logClient.Use(blockkit.NewRenderer(userDirectory, config.DropBlocks))
+	}
+	if rules := args.GetProjectionRules(); len(rules) > 0 {
+		projector, err := projection.New(rules)
+		if err != nil {
+			log.Fatalln("Not able to initialize logtypes rules, err", err)
+		}
+		logClient.Use(projector)
+	}
+	// Redaction runs last, after enrichment and rendering added their attributes
+	if config, salt := args.GetRedaction(); config.Enabled {
+		redactor, err := redaction.New(config, salt)
+		if err != nil {
+			log.Fatalln("Not able to initialize redaction, err", err)
+		}
+		logClient.Use(redactor)
+	}
+
+	var ws []*workspaces.Workspace
+	for _, config := range args.GetWorkspaces() {
+		w, err := workspaces.NewWorkspace(config, logClient)
+		if err != nil {
+			log.Fatalln("**** ", err, " ***** ")
+		}
+		ws = append(ws, w)
+	}
+	if args.GetCommand() == args.CheckScopesCommand {
+		os.Exit(checkScopes(ws))
+	}
+
+	for _, w := range ws {
+		preflightScopes(w)
+		// Collect team information
+		if err := w.DiscoverTeams(); err != nil {
+			log.Fatalln("Not able to fetch teams list with the provided token, workspace: ", w.Name, err)
+		}
+		slog.Info("Starting Slack API logs collection for", "workspace", w.Name, "teamsInfo", w.TeamsInfo)
+		startCollectors(w)
+	}
+	if args.GetEvents().Enabled || args.GetSocketMode().Enabled {
+		startEventSources(ws)
 	}
 	select {}
-	slog.Info("Exiting Slack API logs collection")
 }
@@ -0,0 +1,159 @@
+package membershiplogs
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"time"
+
+	"slackLogs/internal/channelfilter"
+	"slackLogs/internal/channelregistry"
+	"slackLogs/internal/common"
+	"slackLogs/internal/constants"
+	"slackLogs/internal/logclient"
+	"slackLogs/internal/model"
+	"slackLogs/internal/state"
+)
+
+var (
+	logtype       = "ChannelMembership"
+	changeLogtype = "ChannelMembershipChange"
+)
+
+type MembershipLogsHandler struct {
+	Client   *logclient.LogClient
+	Registry *channelregistry.Registry
+	Filter   *channelfilter.Filter
+	batch    *logclient.SnapshotBatch // Snapshots are team id/channel id -> sorted member ids
+}
+
+func NewMembershipLogsHandler(client *logclient.LogClient, registry *channelregistry.Registry, filter *channelfilter.Filter) (*MembershipLogsHandler, error) {
+	snapshots, err := state.Open("channelMembership")
+	if err != nil {
+		return nil, err
+	}
+	return &MembershipLogsHandler{Client: client, Registry: registry, Filter: filter, batch: logclient.NewSnapshotBatch(client, snapshots)}, nil
+}
+
+// conversationsMembersResponse contains slack API successful response
+// https://api.slack.com/methods/conversations.members#examples
+type conversationsMembersResponse struct {
+	Ok               bool     `json:"ok"`
+	Members          []string `json:"members"`
+	ResponseMetaData struct {
+		NextCursor string `json:"next_cursor"`
+	} `json:"response_metadata"`
+	ReqError string                 `json:"error"`
+	Random   map[string]interface{} `json:"-"`
+}
+
+func getChannelMembers(token string, channelId string) ([]string, error) {
+	nextCursor := ""
+	var members []string
+	for {
+		slackClient := common.NewSlackClient(constants.SlackChannelMembersAPIURL, token, nextCursor)
+		params := map[string]string{
+			"channel": channelId,
+			"limit":   strconv.Itoa(1000),
+		}
+		var responseData conversationsMembersResponse
+		errSlack := slackClient.SendRequest(common.WaitAndRetry, &responseData, params)
+		if errSlack != nil {
+			return members, errSlack
+		}
+		if !responseData.Ok {
+			return members, fmt.Errorf("Slack API error %v", responseData.ReqError)
+		}
+		members = append(members, responseData.Members...)
+		next := responseData.ResponseMetaData.NextCursor
+		if next == "" {
+			break
+		}
+		nextCursor = next
+	}
+	sort.Strings(members)
+	return members, nil
+}
+
+// transformMembershipLogs adds a record per member and, when a previous snapshot
+// exists, a change record per member who joined or left since then
+func transformMembershipLogs(batch *logclient.SnapshotBatch, members []string, previous []string, hasPrevious bool, channelId string, channelName string, teamName string) error {
+	ts := time.Now().Unix()
+	for _, member := range members {
+		err := batch.Add(logtype, ts, model.ChannelMember{ChannelID: channelId, ChannelName: channelName, UserID: member, TeamName: teamName, SnapshotTime: ts})
+		if err != nil {
+			return err
+		}
+	}
+	if !hasPrevious {
+		return nil
+	}
+	joined, left := state.DiffSets(previous, members)
+	for _, member := range joined {
+		err := batch.Add(changeLogtype, ts, model.ChannelMembershipChange{ChannelID: channelId, ChannelName: channelName, UserID: member, Change: "joined", TeamName: teamName, SnapshotTime: ts})
+		if err != nil {
+			return err
+		}
+	}
+	for _, member := range left {
+		err := batch.Add(changeLogtype, ts, model.ChannelMembershipChange{ChannelID: channelId, ChannelName: channelName, UserID: member, Change: "left", TeamName: teamName, SnapshotTime: ts})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ml *MembershipLogsHandler) ResetLogs() {
+	if err := ml.batch.Export(); err != nil {
+		slog.Error("Not able to store channel membership snapshots", "error", err)
+	}
+}
+
+func (ml *MembershipLogsHandler) Collect(token string, teamId string, teamName string) error {
+	channels, ok := ml.Registry.Latest(teamId)
+	if !ok {
+		slog.Warn("Channels of the team are not discovered yet, skipping channel memberships", "team", teamName)
+		return nil
+	}
+	selected, skipped := 0, 0
+	for channelId, channelName := range channels.Names() {
+		if !ml.Filter.MatchChannel(channels.FilterChannel(channelId)) {
+			continue
+		}
+		selected++
+		members, err := getChannelMembers(token, channelId)
+		if err != nil {
+			// e.g. channel_not_found or not_in_channel for private channels and DMs the token can't read
+			slog.Debug("Not able to get the members of a channel, skipping it", "channel", channelId, "error", err)
+			skipped++
+			continue
+		}
+		key := teamId + "/" + channelId
+		previous, hasPrevious, err := ml.batch.Previous(key)
+		if err != nil {
+			return err
+		}
+		err = transformMembershipLogs(ml.batch, members, previous, hasPrevious, channelId, channelName, teamName)
+		if err != nil {
+			return err
+		}
+		ml.batch.SetSnapshot(key, members)
+		// Check total collected logs size and maximum allowed logs size in a single request
+		if ml.batch.Full() {
+			if err = ml.batch.Export(); err != nil {
+				return err
+			}
+		}
+	}
+	if skipped > 0 {
+		slog.Warn("Skipped channels whose members could not be read", "team", teamName, "skipped", skipped, "selected", selected)
+	}
+	slog.Info("Collected channel memberships", "team", teamName, "channels", selected-skipped, "of", channels.Len(), "version", channels.Version)
+	// Flush rest of the logs
+	if err := ml.batch.Export(); err != nil {
+		return err
+	}
+	return ml.batch.Save()
+}
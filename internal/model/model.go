@@ -5,7 +5,7 @@ type User struct {
         UserID            string         `json:"id"`
         TeamID            string         `json:"team_id"`
         TeamName          string         `json:"team_name"` // This is not part of UserList repsonse.
-        Billable          bool         `json:"billable"` // This is not part of UserList repsonse.
+        Billable          *bool        `json:"billable,omitempty"` // This is not part of UserList repsonse. Left out when unknown.
         Name              string         `json:"name"`
         Deleted           bool           `json:"deleted"`
         Color             string         `json:"color"`
@@ -51,38 +51,109 @@ type Channel struct {
         ID               string         `json:"id"`
         Name             string         `json:"name"`
         NumMembers       int             `json:"num_members"`
+        IsExtShared      bool            `json:"is_ext_shared"` // Slack Connect channel
+        IsChannel        bool            `json:"is_channel"`
+        IsGroup          bool            `json:"is_group"`
+        IsIM             bool            `json:"is_im"`
+        IsMpIM           bool            `json:"is_mpim"`
+        IsPrivate        bool            `json:"is_private"`
+        IsArchived       bool            `json:"is_archived"`
+        IsShared         bool            `json:"is_shared"`
+        IsOrgShared      bool            `json:"is_org_shared"`
+        ContextTeamID    string          `json:"context_team_id,omitempty"` // Team which created a channel shared within a Grid org
+        SharedTeamIDs    []string        `json:"shared_team_ids,omitempty"`
+        User             string          `json:"user,omitempty"` // The other user of an IM
+        Type             string          `json:"channel_type"` // This is not part of conversations.list response.
         Random           map[string]interface{} `json:"-"`
 	TeamName         string            `json:"team_name"`
 }
 
+// ChannelType returns the conversations.list type of the channel
+func (c Channel) ChannelType() string {
+	switch {
+	case c.IsIM:
+		return "im"
+	case c.IsMpIM:
+		return "mpim"
+	case c.IsPrivate || c.IsGroup:
+		return "private_channel"
+	default:
+		return "public_channel"
+	}
+}
+
 type Conversation struct {
 	Type             string            `json:"type"`
 	ChannelID        string
 	ChannelName      string
+	ChannelType      string            `json:"channel_type"`
 	TeamName         string            `json:"team"`
 	AppID            string            `json:"app_id"`
 	Text             string            `json:"text"`
 	User             string            `json:"user"`
 	TimeStamp        string                     `json:"ts"`
 	Blocks           []Block   `json:"blocks"`
-	RepliesList        []ConversationReply
 	ReplyCount       int               `json:"reply_count"`
+	LatestReply      string            `json:"latest_reply,omitempty"`
+	ParentUserID     string            `json:"parent_user_id,omitempty"`
+	SubType          string            `json:"subtype,omitempty"`
+	ThreadTS         string            `json:"thread_ts,omitempty"`
+	Edited           *MessageEdit      `json:"edited,omitempty"`
+	Files            []MessageFile     `json:"files,omitempty"`
+	Attachments      []map[string]interface{} `json:"attachments,omitempty"`
+	Reactions        []Reaction        `json:"reactions,omitempty"`
 	Random           map[string]interface{} `json:"-"`
 }
 
-type ConversationReply struct {
-	Type             string         `json:"type"`
-	User             string         `json:"user"`
-	Text             string         `json:"text"`
-	ThreadTS	 string         `json:"thread_ts"`
-	ParentUserID     string         `json:"parent_user_id"`
-	TimeStamp        string         `json:"ts"`
+// MessageEdit tells who last edited a message and when
+type MessageEdit struct {
+	User      string `json:"user"`
+	TimeStamp string `json:"ts"`
+}
+
+// MessageFile is a file shared in a message
+type MessageFile struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Title     string `json:"title"`
+	MimeType  string `json:"mimetype"`
+	FileType  string `json:"filetype"`
+	Size      int64  `json:"size"`
+	User      string `json:"user"`
+	Permalink string `json:"permalink"`
+}
+
+// Reaction is an emoji reaction to a message
+type Reaction struct {
+	Name  string   `json:"name"`
+	Count int      `json:"count"`
+	Users []string `json:"users"`
+}
+
+// ConversationChange is an edit or deletion of a collected message found by reconciliation
+type ConversationChange struct {
+	ChangeType   string `json:"change_type"` // message_edited or message_deleted
+	ChannelID    string `json:"channel_id"`
+	ChannelName  string `json:"channel_name"`
+	ChannelType  string `json:"channel_type"`
+	TeamName     string `json:"team"`
+	TimeStamp    string `json:"ts"`
+	User         string `json:"user"`
+	Text         string `json:"text,omitempty"` // Current text of an edited message
+	PreviousText string `json:"previous_text"`  // Text of the message when it was collected before
+	OriginalText string `json:"original_text"`  // Text of the message when it was collected first
+	EditedBy     string `json:"edited_by,omitempty"`
+	EditedTS     string `json:"edited_ts,omitempty"`
 }
 
 type Block struct {
 	Type         string         `json:"type"`
 	BlockID      string         `json:"block_id"`
 	Elements     []map[string]interface{} `json:"elements"`
+	Text         map[string]interface{}   `json:"text,omitempty"`   // section and header blocks
+	Fields       []map[string]interface{} `json:"fields,omitempty"` // section blocks
+	Title        map[string]interface{}   `json:"title,omitempty"`  // image blocks
+	AltText      string                   `json:"alt_text,omitempty"`
 }
 
 type ChannelSubInfo struct {
@@ -115,3 +186,247 @@ type AccessLog struct {
 	TeamName   string     `json:"team_name"`
         Random     map[string]interface{} `json:"-"`
 }
+
+// IntegrationLog contains an app or integration change of a team
+// https://api.slack.com/methods/team.integrationLogs#examples
+type IntegrationLog struct {
+        ServiceID   string     `json:"service_id,omitempty"`
+        ServiceType string     `json:"service_type,omitempty"`
+        AppID       string     `json:"app_id,omitempty"`
+        AppType     string     `json:"app_type,omitempty"`
+        UserID      string     `json:"user_id"`
+        UserName    string     `json:"user_name"`
+        Channel     string     `json:"channel,omitempty"`
+        Date        string     `json:"date"`
+        ChangeType  string     `json:"change_type"`
+        Scope       string     `json:"scope,omitempty"`
+        Reason      string     `json:"reason,omitempty"`
+        TeamName    string     `json:"team_name"`
+        Random      map[string]interface{} `json:"-"`
+}
+
+// File contains the metadata of a shared file
+// https://api.slack.com/types/file
+type File struct {
+        ID               string     `json:"id"`
+        Created          int64      `json:"created"`
+        Name             string     `json:"name"`
+        Title            string     `json:"title"`
+        MimeType         string     `json:"mimetype"`
+        FileType         string     `json:"filetype"`
+        PrettyType       string     `json:"pretty_type"`
+        Size             int64      `json:"size"`
+        UserID           string     `json:"user"`
+        UserName         string     `json:"user_name"` // This is not part of files.list response.
+        IsExternal       bool       `json:"is_external"` // Hosted outside of Slack, e.g. Google Drive
+        ExternalType     string     `json:"external_type,omitempty"`
+        IsPublic         bool       `json:"is_public"`
+        PublicURLShared  bool       `json:"public_url_shared"`
+        Permalink        string     `json:"permalink"`
+        Channels         []string   `json:"channels"`
+        Groups           []string   `json:"groups"`
+        IMs              []string   `json:"ims"`
+        ChannelNames     []string   `json:"channel_names"` // This is not part of files.list response.
+        ExternalChannels []string   `json:"external_channels"` // Slack Connect channels the file is shared into
+        SharedExternally bool       `json:"shared_externally"` // Publicly shared or shared into a Slack Connect channel
+        TeamName         string     `json:"team_name"`
+        Random           map[string]interface{} `json:"-"`
+}
+
+// Event is a record built from a Slack Events API callback
+// https://api.slack.com/apis/connections/events-api#callback-field
+type Event struct {
+	EventID      string                 `json:"event_id"`
+	EventTime    int64                  `json:"event_time"`
+	TeamID       string                 `json:"team_id"`
+	TeamName     string                 `json:"team_name"`
+	EnterpriseID string                 `json:"enterprise_id,omitempty"`
+	Type         string                 `json:"type"`
+	Subtype      string                 `json:"subtype,omitempty"`
+	Channel      string                 `json:"channel,omitempty"`
+	ChannelType  string                 `json:"channel_type,omitempty"`
+	User         string                 `json:"user,omitempty"`
+	Text         string                 `json:"text,omitempty"`
+	TimeStamp    string                 `json:"ts,omitempty"`
+	ThreadTS     string                 `json:"thread_ts,omitempty"`
+	PreviousText string                 `json:"previous_text,omitempty"` // Text before a message was edited or deleted
+	DeletedTS    string                 `json:"deleted_ts,omitempty"`
+	Event        map[string]interface{} `json:"event"` // The event payload as sent by Slack
+}
+
+// ChannelMember is a member of a channel at the time of a membership snapshot
+type ChannelMember struct {
+	ChannelID    string `json:"channel_id"`
+	ChannelName  string `json:"channel_name"`
+	UserID       string `json:"user_id"`
+	TeamName     string `json:"team_name"`
+	SnapshotTime int64  `json:"snapshot_time"`
+}
+
+// ChannelMembershipChange is a join or leave observed between two membership snapshots
+type ChannelMembershipChange struct {
+	ChannelID    string `json:"channel_id"`
+	ChannelName  string `json:"channel_name"`
+	UserID       string `json:"user_id"`
+	Change       string `json:"change"` // joined or left
+	TeamName     string `json:"team_name"`
+	SnapshotTime int64  `json:"snapshot_time"`
+}
+
+// UserGroup contains the definition and members of a user group
+// https://api.slack.com/types/usergroup
+type UserGroup struct {
+	ID          string   `json:"id"`
+	TeamID      string   `json:"team_id"`
+	Name        string   `json:"name"`
+	Handle      string   `json:"handle"`
+	Description string   `json:"description"`
+	IsExternal  bool     `json:"is_external"`
+	DateCreate  int64    `json:"date_create"`
+	DateUpdate  int64    `json:"date_update"`
+	DateDelete  int64    `json:"date_delete"`
+	CreatedBy   string   `json:"created_by"`
+	UpdatedBy   string   `json:"updated_by"`
+	Users       []string `json:"users"`
+	MemberCount int      `json:"member_count"` // This is not part of usergroups.list response.
+	TeamName    string   `json:"team_name"`
+	Random      map[string]interface{} `json:"-"`
+}
+
+// UserGroupMember is a member of a user group at the time of a snapshot
+type UserGroupMember struct {
+	UserGroupID  string `json:"usergroup_id"`
+	Handle       string `json:"handle"`
+	UserID       string `json:"user_id"`
+	TeamName     string `json:"team_name"`
+	SnapshotTime int64  `json:"snapshot_time"`
+}
+
+// UserGroupChange is a user added to or removed from a user group between two snapshots
+type UserGroupChange struct {
+	UserGroupID  string `json:"usergroup_id"`
+	Handle       string `json:"handle"`
+	UserID       string `json:"user_id"`
+	Change       string `json:"change"` // added or removed
+	TeamName     string `json:"team_name"`
+	SnapshotTime int64  `json:"snapshot_time"`
+}
+
+// GridTeam is a workspace of an Enterprise Grid org
+// https://api.slack.com/methods/admin.teams.list#examples
+type GridTeam struct {
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	Discoverability string `json:"discoverability"`
+	TeamURL         string `json:"team_url"`
+	PrimaryOwner    struct {
+		UserID string `json:"user_id"`
+		Email  string `json:"email"`
+	} `json:"primary_owner"`
+	OrgName string                 `json:"org_name"` // This is not part of admin.teams.list response.
+	Random  map[string]interface{} `json:"-"`
+}
+
+// GridUser is a user of an Enterprise Grid org
+// https://api.slack.com/methods/admin.users.list#examples
+type GridUser struct {
+	ID                string                 `json:"id"`
+	Email             string                 `json:"email"`
+	Username          string                 `json:"username"`
+	FullName          string                 `json:"full_name"`
+	IsAdmin           bool                   `json:"is_admin"`
+	IsOwner           bool                   `json:"is_owner"`
+	IsPrimaryOwner    bool                   `json:"is_primary_owner"`
+	IsRestricted      bool                   `json:"is_restricted"`
+	IsUltraRestricted bool                   `json:"is_ultra_restricted"`
+	IsBot             bool                   `json:"is_bot"`
+	IsActive          bool                   `json:"is_active"`
+	DateCreated       int64                  `json:"date_created"`
+	DeactivatedTs     int64                  `json:"deactivated_ts"`
+	ReactivatedTs     int64                  `json:"reactivated_ts"`
+	ExpirationTs      int64                  `json:"expiration_ts"`
+	Workspaces        []string               `json:"workspaces"`
+	TeamName          string                 `json:"team_name"` // This is not part of admin.users.list response.
+	Random            map[string]interface{} `json:"-"`
+}
+
+// GridConversation is a channel of an Enterprise Grid org
+// https://api.slack.com/methods/admin.conversations.search#examples
+type GridConversation struct {
+	ID                 string                 `json:"id"`
+	Name               string                 `json:"name"`
+	Purpose            string                 `json:"purpose"`
+	MemberCount        int                    `json:"member_count"`
+	Created            int64                  `json:"created"`
+	CreatorID          string                 `json:"creator_id"`
+	IsPrivate          bool                   `json:"is_private"`
+	IsArchived         bool                   `json:"is_archived"`
+	IsGeneral          bool                   `json:"is_general"`
+	IsExtShared        bool                   `json:"is_ext_shared"`
+	IsOrgShared        bool                   `json:"is_org_shared"`
+	IsGlobalShared     bool                   `json:"is_global_shared"`
+	IsFrozen           bool                   `json:"is_frozen"`
+	LastActivityTs     int64                  `json:"last_activity_ts"`
+	ConnectedTeamIDs   []string               `json:"connected_team_ids"`
+	ConversationHostID string                 `json:"conversation_host_id"`
+	TeamName           string                 `json:"team_name"` // This is not part of admin.conversations.search response.
+	Random             map[string]interface{} `json:"-"`
+}
+
+// GridApp is an app approved or restricted by the admins of an Enterprise Grid org
+// https://api.slack.com/methods/admin.apps.approved.list#examples
+type GridApp struct {
+	App struct {
+		ID                     string `json:"id"`
+		Name                   string `json:"name"`
+		Description            string `json:"description"`
+		AppDirectoryURL        string `json:"app_directory_url"`
+		IsAppDirectoryApproved bool   `json:"is_app_directory_approved"`
+		IsInternal             bool   `json:"is_internal"`
+	} `json:"app"`
+	Scopes []struct {
+		Name        string `json:"name"`
+		IsSensitive bool   `json:"is_sensitive"`
+		TokenType   string `json:"token_type"`
+	} `json:"scopes"`
+	DateUpdated    int64 `json:"date_updated"`
+	LastResolvedBy struct {
+		ActorID   string `json:"actor_id"`
+		ActorType string `json:"actor_type"`
+	} `json:"last_resolved_by"`
+	Status   string                 `json:"status"`    // approved or restricted, this is not part of the response.
+	TeamName string                 `json:"team_name"` // This is not part of the response.
+	Random   map[string]interface{} `json:"-"`
+}
+
+// GridSessionClient describes the client of a session when it was created or last used
+type GridSessionClient struct {
+	DeviceHardware     string `json:"device_hardware"`
+	OS                 string `json:"os"`
+	OSVersion          string `json:"os_version"`
+	SlackClientVersion string `json:"slack_client_version"`
+	IP                 string `json:"ip"`
+}
+
+// GridSession is an active session of a user of an Enterprise Grid org
+// https://api.slack.com/methods/admin.users.session.list#examples
+type GridSession struct {
+	UserID    string                 `json:"user_id"`
+	TeamID    string                 `json:"team_id"`
+	SessionID int64                  `json:"session_id"`
+	Created   *GridSessionClient     `json:"created,omitempty"`
+	Recent    *GridSessionClient     `json:"recent,omitempty"`
+	TeamName  string                 `json:"team_name"` // This is not part of admin.users.session.list response.
+	// The fields below are not part of admin.users.session.list response
+	IP                    string `json:"ip"`      // IP of the most recent use of the session
+	Country               string `json:"country"` // Country of the IP in team.accessLogs, empty when unknown
+	CountryUnknown        bool   `json:"country_unknown"` // The IP has no login in team.accessLogs, the country is not checked
+	FirstSeen             int64  `json:"first_seen"`
+	ExpiresBy             int64  `json:"expires_by,omitempty"`       // Latest expiry, first_seen plus the session duration setting. The session may have started before it was first seen
+	SessionDuration       int64  `json:"session_duration,omitempty"` // From admin.users.session.getSettings, in seconds
+	DesktopAppBrowserQuit bool   `json:"desktop_app_browser_quit"`
+	UserActive            bool   `json:"user_active"`
+	DeactivatedUser       bool   `json:"deactivated_user"`   // The session persists after the user was deactivated
+	UnexpectedCountry     bool   `json:"unexpected_country"` // The country is not in the allowed countries
+	Random    map[string]interface{} `json:"-"`
+}
@@ -0,0 +1,208 @@
+package projection
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// AllLogTypes selects rules which apply to every logtype, in addition to the rules of the logtype
+const AllLogTypes = "*"
+
+// Fields selects the exported fields of a record by dot separated paths, e.g. actor.user.email.
+// An empty include list keeps every field which is not excluded.
+type Fields struct {
+	Include []string `yaml:"include"`
+	Exclude []string `yaml:"exclude"`
+}
+
+// Condition matches records by the value of a field. A condition with several checks
+// matches when all of them match.
+type Condition struct {
+	Field   string        `yaml:"field"`
+	Equals  interface{}   `yaml:"equals"`
+	In      []interface{} `yaml:"in"`
+	Matches string        `yaml:"matches"` // Regular expression
+	CIDR    []string      `yaml:"cidr"`    // IP address in one of the networks
+	Exists  *bool         `yaml:"exists"`
+
+	matches  *regexp.Regexp
+	networks []*net.IPNet
+}
+
+// Rules are the projection of a logtype, records matching a dropIf condition are not exported
+type Rules struct {
+	Fields Fields      `yaml:"fields"`
+	DropIf []Condition `yaml:"dropIf"`
+}
+
+// Projector drops records and fields per logtype before logs are exported
+type Projector struct {
+	rules map[string]Rules // logtype -> rules
+}
+
+// New checks the conditions and compiles their expressions and networks
+func New(rules map[string]Rules) (*Projector, error) {
+	p := &Projector{rules: make(map[string]Rules, len(rules))}
+	for logtype, r := range rules {
+		for i := range r.DropIf {
+			c := &r.DropIf[i]
+			if c.Field == "" {
+				return nil, fmt.Errorf("dropIf condition of %s without a field", logtype)
+			}
+			if c.Equals == nil && c.In == nil && c.Matches == "" && c.CIDR == nil && c.Exists == nil {
+				return nil, fmt.Errorf("dropIf condition of %s on %s without a check, use equals, in, matches, cidr or exists", logtype, c.Field)
+			}
+			if c.Matches != "" {
+				expr, err := regexp.Compile(c.Matches)
+				if err != nil {
+					return nil, fmt.Errorf("invalid dropIf expression %s of %s: %v", c.Matches, logtype, err)
+				}
+				c.matches = expr
+			}
+			for _, cidr := range c.CIDR {
+				_, network, err := net.ParseCIDR(cidr)
+				if err != nil {
+					return nil, fmt.Errorf("invalid dropIf network %s of %s: %v", cidr, logtype, err)
+				}
+				c.networks = append(c.networks, network)
+			}
+		}
+		p.rules[logtype] = r
+	}
+	return p, nil
+}
+
+func (p *Projector) Process(logtype string, record map[string]interface{}) bool {
+	var applicable []Rules
+	for _, lt := range []string{AllLogTypes, logtype} {
+		if r, ok := p.rules[lt]; ok {
+			applicable = append(applicable, r)
+		}
+	}
+	// Conditions see the whole record, also fields which are not exported
+	for _, r := range applicable {
+		for _, c := range r.DropIf {
+			if c.match(record) {
+				return false
+			}
+		}
+	}
+	for _, r := range applicable {
+		if len(r.Fields.Include) > 0 {
+			projected := make(map[string]interface{})
+			for _, path := range r.Fields.Include {
+				if value, ok := lookup(record, path); ok {
+					set(projected, path, value)
+				}
+			}
+			for k := range record {
+				delete(record, k)
+			}
+			for k, v := range projected {
+				record[k] = v
+			}
+		}
+		for _, path := range r.Fields.Exclude {
+			remove(record, path)
+		}
+	}
+	return true
+}
+
+func (c *Condition) match(record map[string]interface{}) bool {
+	value, found := lookup(record, c.Field)
+	if c.Exists != nil && found != *c.Exists {
+		return false
+	}
+	if !found {
+		// Only exists can match a missing field
+		return c.Exists != nil
+	}
+	text := format(value)
+	if c.Equals != nil && text != format(c.Equals) {
+		return false
+	}
+	if c.In != nil {
+		in := false
+		for _, v := range c.In {
+			if text == format(v) {
+				in = true
+				break
+			}
+		}
+		if !in {
+			return false
+		}
+	}
+	if c.matches != nil && !c.matches.MatchString(text) {
+		return false
+	}
+	if c.CIDR != nil {
+		ip := net.ParseIP(text)
+		in := false
+		for _, network := range c.networks {
+			if ip != nil && network.Contains(ip) {
+				in = true
+				break
+			}
+		}
+		if !in {
+			return false
+		}
+	}
+	return true
+}
+
+// format formats a value for comparison, numbers of JSON records and of the config compare
+// equal, e.g. 1700000000 instead of 1.7e+09
+func format(value interface{}) string {
+	if f, ok := value.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
+
+func lookup(record map[string]interface{}, path string) (interface{}, bool) {
+	keys := strings.Split(path, ".")
+	var current interface{} = record
+	for _, key := range keys {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func set(record map[string]interface{}, path string, value interface{}) {
+	keys := strings.Split(path, ".")
+	object := record
+	for _, key := range keys[:len(keys)-1] {
+		child, ok := object[key].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			object[key] = child
+		}
+		object = child
+	}
+	object[keys[len(keys)-1]] = value
+}
+
+func remove(record map[string]interface{}, path string) {
+	keys := strings.Split(path, ".")
+	object := record
+	for _, key := range keys[:len(keys)-1] {
+		child, ok := object[key].(map[string]interface{})
+		if !ok {
+			return
+		}
+		object = child
+	}
+	delete(object, keys[len(keys)-1])
+}
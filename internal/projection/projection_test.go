@@ -0,0 +1,158 @@
+package projection
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const auditRecord = `{"id":"A1","action":"user_login","date_create":1700000000,
+	"actor":{"type":"user","user":{"id":"U1","email":"jane@example.com"}},
+	"context":{"ip_address":"10.1.2.3","ua":"Mozilla"}}`
+
+func TestProcess(t *testing.T) {
+	exists, missing := true, false
+	tests := []struct {
+		name  string
+		rules map[string]Rules
+		want  string // empty when the record is dropped
+	}{
+		{
+			"no rules",
+			nil,
+			auditRecord,
+		},
+		{
+			"include",
+			map[string]Rules{"SlackAuditLogs": {Fields: Fields{Include: []string{"id", "actor.user.id", "context.missing"}}}},
+			`{"id":"A1","actor":{"user":{"id":"U1"}}}`,
+		},
+		{
+			"exclude",
+			map[string]Rules{"SlackAuditLogs": {Fields: Fields{Exclude: []string{"context", "actor.user.email", "missing.field"}}}},
+			`{"id":"A1","action":"user_login","date_create":1700000000,"actor":{"type":"user","user":{"id":"U1"}}}`,
+		},
+		{
+			"include then exclude",
+			map[string]Rules{"SlackAuditLogs": {Fields: Fields{Include: []string{"id", "actor.user"}, Exclude: []string{"actor.user.email"}}}},
+			`{"id":"A1","actor":{"user":{"id":"U1"}}}`,
+		},
+		{
+			"rules of all logtypes",
+			map[string]Rules{
+				AllLogTypes:      {Fields: Fields{Exclude: []string{"context"}}},
+				"SlackAuditLogs": {Fields: Fields{Exclude: []string{"actor"}}},
+			},
+			`{"id":"A1","action":"user_login","date_create":1700000000}`,
+		},
+		{
+			"rules of another logtype",
+			map[string]Rules{"SlackMessages": {Fields: Fields{Include: []string{"text"}}}},
+			auditRecord,
+		},
+		{
+			"dropIf in network",
+			map[string]Rules{"SlackAuditLogs": {DropIf: []Condition{{Field: "context.ip_address", CIDR: []string{"192.168.0.0/16", "10.0.0.0/8"}}}}},
+			"",
+		},
+		{
+			"dropIf outside network",
+			map[string]Rules{"SlackAuditLogs": {DropIf: []Condition{{Field: "context.ip_address", CIDR: []string{"10.2.0.0/16"}}}}},
+			auditRecord,
+		},
+		{
+			"dropIf network of a field without an address",
+			map[string]Rules{"SlackAuditLogs": {DropIf: []Condition{{Field: "context.ua", CIDR: []string{"0.0.0.0/0"}}}}},
+			auditRecord,
+		},
+		{
+			"dropIf on an excluded field",
+			map[string]Rules{"SlackAuditLogs": {Fields: Fields{Exclude: []string{"context"}}, DropIf: []Condition{{Field: "context.ip_address", CIDR: []string{"10.0.0.0/8"}}}}},
+			"",
+		},
+		{
+			"dropIf equals number",
+			map[string]Rules{"SlackAuditLogs": {DropIf: []Condition{{Field: "date_create", Equals: 1700000000}}}},
+			"",
+		},
+		{
+			"dropIf in",
+			map[string]Rules{"SlackAuditLogs": {DropIf: []Condition{{Field: "action", In: []interface{}{"user_logout", "user_login"}}}}},
+			"",
+		},
+		{
+			"dropIf matches",
+			map[string]Rules{"SlackAuditLogs": {DropIf: []Condition{{Field: "actor.user.email", Matches: `@example\.com$`}}}},
+			"",
+		},
+		{
+			"dropIf needs all checks",
+			map[string]Rules{"SlackAuditLogs": {DropIf: []Condition{{Field: "action", Equals: "user_login", Matches: "^file_"}}}},
+			auditRecord,
+		},
+		{
+			"dropIf exists",
+			map[string]Rules{"SlackAuditLogs": {DropIf: []Condition{{Field: "context.ua", Exists: &exists}}}},
+			"",
+		},
+		{
+			"dropIf missing",
+			map[string]Rules{"SlackAuditLogs": {DropIf: []Condition{{Field: "entity", Exists: &missing}}}},
+			"",
+		},
+		{
+			"dropIf on a missing field",
+			map[string]Rules{"SlackAuditLogs": {DropIf: []Condition{{Field: "entity", Equals: "x"}}}},
+			auditRecord,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := New(tt.rules)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var record map[string]interface{}
+			if err := json.Unmarshal([]byte(auditRecord), &record); err != nil {
+				t.Fatal(err)
+			}
+			keep := p.Process("SlackAuditLogs", record)
+			if tt.want == "" {
+				if keep {
+					t.Error("record was kept, want it dropped")
+				}
+				return
+			}
+			if !keep {
+				t.Fatal("record was dropped")
+			}
+			var want map[string]interface{}
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(record, want) {
+				got, _ := json.Marshal(record)
+				t.Errorf("record = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition Condition
+	}{
+		{"without a field", Condition{Equals: "x"}},
+		{"without a check", Condition{Field: "action"}},
+		{"invalid expression", Condition{Field: "action", Matches: "("}},
+		{"invalid network", Condition{Field: "context.ip_address", CIDR: []string{"10.0.0.0/33"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(map[string]Rules{"SlackAuditLogs": {DropIf: []Condition{tt.condition}}}); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package redaction
+
+import (
+	"regexp"
+	"strings"
+)
+
+// detector finds one kind of personal data in text, validate rules out matches of the
+// pattern which are not that kind of data, e.g. numbers failing the Luhn check
+type detector struct {
+	name     string
+	pattern  *regexp.Regexp
+	validate func(match string) bool
+}
+
+// Built-in detectors, in the order they run: a credit card number is redacted before it
+// can be taken for a phone number
+var builtinDetectors = []detector{
+	{name: "email", pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)},
+	{name: "credit_card", pattern: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`), validate: isCardNumber},
+	// US social security numbers
+	{name: "national_id", pattern: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`), validate: isSSN},
+	{name: "phone", pattern: regexp.MustCompile(`\+?\(?\d[\d ().-]{7,}\d\b`), validate: isPhoneNumber},
+}
+
+// The whole value of a field, for fields which are personal data as a whole
+const allDetector = "all"
+
+var allPattern = regexp.MustCompile(`(?s)^.+$`)
+
+func digits(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// luhn reports whether a number passes the Luhn checksum of payment card numbers
+func luhn(number string) bool {
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		d := int(number[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+func isCardNumber(match string) bool {
+	number := digits(match)
+	return len(number) >= 13 && len(number) <= 19 && luhn(number)
+}
+
+func isSSN(match string) bool {
+	area, group, serial := match[0:3], match[4:6], match[7:11]
+	return area != "000" && area != "666" && area[0] != '9' && group != "00" && serial != "0000"
+}
+
+var datePrefix = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}`)
+
+// isPhoneNumber accepts 10 to 15 digits written as a phone number, with a leading + or separators,
+// so plain ids and timestamps are not taken for phone numbers
+func isPhoneNumber(match string) bool {
+	n := len(digits(match))
+	if n < 10 || n > 15 || datePrefix.MatchString(match) {
+		return false
+	}
+	return strings.HasPrefix(match, "+") || strings.ContainsAny(match, " ().-")
+}
@@ -0,0 +1,63 @@
+package redaction
+
+import "testing"
+
+func TestIsCardNumber(t *testing.T) {
+	tests := []struct {
+		match string
+		want  bool
+	}{
+		{"4111111111111111", true},
+		{"4111 1111 1111 1111", true},
+		{"4111-1111-1111-1111", true},
+		{"378282246310005", true}, // 15 digits
+		{"4111111111111112", false},
+		{"411111111111", false},         // too short
+		{"41111111111111111111", false}, // too long
+	}
+	for _, tt := range tests {
+		if got := isCardNumber(tt.match); got != tt.want {
+			t.Errorf("isCardNumber(%q) = %v, want %v", tt.match, got, tt.want)
+		}
+	}
+}
+
+func TestIsSSN(t *testing.T) {
+	tests := []struct {
+		match string
+		want  bool
+	}{
+		{"123-45-6789", true},
+		{"000-45-6789", false},
+		{"666-45-6789", false},
+		{"912-45-6789", false},
+		{"123-00-6789", false},
+		{"123-45-0000", false},
+	}
+	for _, tt := range tests {
+		if got := isSSN(tt.match); got != tt.want {
+			t.Errorf("isSSN(%q) = %v, want %v", tt.match, got, tt.want)
+		}
+	}
+}
+
+func TestIsPhoneNumber(t *testing.T) {
+	tests := []struct {
+		match string
+		want  bool
+	}{
+		{"+14155552671", true},
+		{"(415) 555-2671", true},
+		{"415.555.2671", true},
+		{"+44 20 7946 0958", true},
+		{"4155552671", false},        // plain number, e.g. an id
+		{"2024-01-02 10:11", false},  // date
+		{"+1 415 555", false},        // too few digits
+		{"+1234567890123456", false}, // too many digits
+	}
+	for _, tt := range tests {
+		if got := isPhoneNumber(tt.match); got != tt.want {
+			t.Errorf("isPhoneNumber(%q) = %v, want %v", tt.match, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package redaction
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Allowed values of Config.Mode and Field.Mode
+const (
+	Mask = "mask" // Replaces personal data with [REDACTED:<detector>]
+	Hash = "hash" // Replaces personal data with [<detector>:<salted hash>], equal values keep equal hashes
+	Drop = "drop" // Removes fields which contain personal data
+)
+
+// Pattern is a custom detector
+type Pattern struct {
+	Name  string `yaml:"name"`
+	Regex string `yaml:"regex"`
+}
+
+// Field selects where personal data is looked for. The path is a dot separated list of keys,
+// a key ending with [] selects every element of an array, e.g. attachments[].text or
+// actor.user.email. Objects and arrays are searched as a whole.
+type Field struct {
+	Path      string   `yaml:"path"`
+	Mode      string   `yaml:"mode"`      // Defaults to the mode of the config
+	Detectors []string `yaml:"detectors"` // Defaults to the detectors of the config
+	LogTypes  []string `yaml:"logtypes"`  // Limits the field to these logtypes
+}
+
+// Config is the redaction block of SlackConfig.yaml
+type Config struct {
+	Enabled   bool      `yaml:"enabled"`
+	Mode      string    `yaml:"mode"`
+	Salt      string    `yaml:"salt"`      // Secret reference, defaults to REDACTION_SALT(_FILE)
+	Detectors []string  `yaml:"detectors"` // Built-in detectors and names of patterns, all of them when empty
+	Patterns  []Pattern `yaml:"patterns"`
+	Fields    []Field   `yaml:"fields"` // DefaultFields when empty
+}
+
+// DefaultFields hold message content and email addresses of the collected logs
+var DefaultFields = []string{"text", "previous_text", "original_text", "text_plain", "blocks", "attachments", "files[].name",
+	"files[].title", "email", "user_email", "actor.user.email", "entity.user.email"}
+
+type field struct {
+	path      []string
+	mode      string
+	detectors []detector
+	logtypes  map[string]bool // empty for every logtype
+}
+
+// Redactor removes personal data from records before they are exported
+type Redactor struct {
+	fields []field
+	salt   []byte
+}
+
+func validMode(mode string) bool {
+	return mode == Mask || mode == Hash || mode == Drop
+}
+
+// New checks the config and compiles its patterns
+func New(config Config, salt string) (*Redactor, error) {
+	mode := strings.ToLower(config.Mode)
+	if mode == "" {
+		mode = Mask
+	}
+	if !validMode(mode) {
+		return nil, fmt.Errorf("invalid redaction mode %s, allowed are mask, hash and drop", config.Mode)
+	}
+	available := make(map[string]detector)
+	var all []detector
+	for _, d := range builtinDetectors {
+		available[d.name] = d
+		all = append(all, d)
+	}
+	for _, p := range config.Patterns {
+		pattern, err := regexp.Compile(p.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %s: %v", p.Name, err)
+		}
+		if p.Name == "" || p.Name == allDetector {
+			return nil, fmt.Errorf("redaction pattern %s needs a name other than all", p.Regex)
+		}
+		available[p.Name] = detector{name: p.Name, pattern: pattern}
+		all = append(all, available[p.Name])
+	}
+	available[allDetector] = detector{name: allDetector, pattern: allPattern}
+	selectDetectors := func(names []string, fallback []detector) ([]detector, error) {
+		if len(names) == 0 {
+			return fallback, nil
+		}
+		var selected []detector
+		for _, name := range names {
+			d, ok := available[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown redaction detector %s", name)
+			}
+			selected = append(selected, d)
+		}
+		return selected, nil
+	}
+	detectors, err := selectDetectors(config.Detectors, all)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := config.Fields
+	if len(fields) == 0 {
+		for _, path := range DefaultFields {
+			fields = append(fields, Field{Path: path})
+		}
+	}
+	r := &Redactor{salt: []byte(salt)}
+	for _, f := range fields {
+		compiled := field{path: strings.Split(f.Path, "."), mode: strings.ToLower(f.Mode), logtypes: make(map[string]bool)}
+		if f.Path == "" {
+			return nil, fmt.Errorf("redaction field without a path")
+		}
+		if compiled.mode == "" {
+			compiled.mode = mode
+		}
+		if !validMode(compiled.mode) {
+			return nil, fmt.Errorf("invalid redaction mode %s of field %s, allowed are mask, hash and drop", f.Mode, f.Path)
+		}
+		if compiled.mode == Hash && salt == "" {
+			return nil, fmt.Errorf("hash mode needs a salt, set REDACTION_SALT or redaction.salt")
+		}
+		if compiled.detectors, err = selectDetectors(f.Detectors, detectors); err != nil {
+			return nil, err
+		}
+		for _, lt := range f.LogTypes {
+			compiled.logtypes[lt] = true
+		}
+		r.fields = append(r.fields, compiled)
+	}
+	return r, nil
+}
+
+func (r *Redactor) Process(logtype string, record map[string]interface{}) bool {
+	for _, f := range r.fields {
+		if len(f.logtypes) == 0 || f.logtypes[logtype] {
+			r.redactPath(record, f.path, f)
+		}
+	}
+	return true
+}
+
+// redactPath follows the path through objects and arrays and redacts the value at its end
+func (r *Redactor) redactPath(object map[string]interface{}, path []string, f field) {
+	key := strings.TrimSuffix(path[0], "[]")
+	value, ok := object[key]
+	if !ok {
+		return
+	}
+	if key == path[0] {
+		if len(path) > 1 {
+			if child, ok := value.(map[string]interface{}); ok {
+				r.redactPath(child, path[1:], f)
+			}
+			return
+		}
+		if redacted, found := r.redactValue(value, f); found && f.mode == Drop {
+			delete(object, key)
+		} else {
+			object[key] = redacted
+		}
+		return
+	}
+	elements, ok := value.([]interface{})
+	if !ok {
+		return
+	}
+	kept := elements[:0]
+	for _, element := range elements {
+		if len(path) > 1 {
+			if child, ok := element.(map[string]interface{}); ok {
+				r.redactPath(child, path[1:], f)
+			}
+			kept = append(kept, element)
+			continue
+		}
+		redacted, found := r.redactValue(element, f)
+		if found && f.mode == Drop {
+			continue
+		}
+		kept = append(kept, redacted)
+	}
+	object[key] = kept
+}
+
+// redactValue redacts the strings of a value and reports whether personal data was found.
+// In drop mode the value is only searched.
+func (r *Redactor) redactValue(value interface{}, f field) (interface{}, bool) {
+	switch v := value.(type) {
+	case string:
+		return r.redactString(v, f)
+	case map[string]interface{}:
+		found := false
+		for k, child := range v {
+			redacted, childFound := r.redactValue(child, f)
+			v[k] = redacted
+			found = found || childFound
+		}
+		return v, found
+	case []interface{}:
+		found := false
+		for i, child := range v {
+			redacted, childFound := r.redactValue(child, f)
+			v[i] = redacted
+			found = found || childFound
+		}
+		return v, found
+	}
+	return value, false
+}
+
+func (r *Redactor) redactString(s string, f field) (string, bool) {
+	found := false
+	for _, d := range f.detectors {
+		s = d.pattern.ReplaceAllStringFunc(s, func(match string) string {
+			if d.validate != nil && !d.validate(match) {
+				return match
+			}
+			found = true
+			return r.replacement(d.name, match, f.mode)
+		})
+	}
+	return s, found
+}
+
+func (r *Redactor) replacement(name string, match string, mode string) string {
+	switch mode {
+	case Hash:
+		mac := hmac.New(sha256.New, r.salt)
+		mac.Write([]byte(match))
+		return "[" + name + ":" + hex.EncodeToString(mac.Sum(nil))[:16] + "]"
+	case Drop:
+		return match
+	}
+	return "[REDACTED:" + name + "]"
+}
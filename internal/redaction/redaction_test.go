@@ -0,0 +1,149 @@
+package redaction
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProcess(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		record string
+		want   string
+	}{
+		{
+			"mask default fields",
+			Config{},
+			`{"text":"mail jane@example.com or call +1 415 555 2671","user":"U1","email":"jane@example.com"}`,
+			`{"text":"mail [REDACTED:email] or call [REDACTED:phone]","user":"U1","email":"[REDACTED:email]"}`,
+		},
+		{
+			"card before phone",
+			Config{},
+			`{"text":"card 4111 1111 1111 1111, ssn 123-45-6789, id 4111111111111112"}`,
+			`{"text":"card [REDACTED:credit_card], ssn [REDACTED:national_id], id 4111111111111112"}`,
+		},
+		{
+			"mask array paths",
+			Config{Fields: []Field{{Path: "files[].name"}, {Path: "attachments[]"}}},
+			`{"files":[{"name":"from jane@example.com"},{"name":"report.pdf"}],"attachments":[{"text":"jane@example.com"},"fine"]}`,
+			`{"files":[{"name":"from [REDACTED:email]"},{"name":"report.pdf"}],"attachments":[{"text":"[REDACTED:email]"},"fine"]}`,
+		},
+		{
+			"mask nested objects",
+			Config{Fields: []Field{{Path: "actor.user.email"}}},
+			`{"actor":{"user":{"email":"jane@example.com","name":"jane@example.com"}}}`,
+			`{"actor":{"user":{"email":"[REDACTED:email]","name":"jane@example.com"}}}`,
+		},
+		{
+			"drop field",
+			Config{Mode: Drop, Fields: []Field{{Path: "text"}, {Path: "email"}}},
+			`{"text":"mail jane@example.com","email":"nobody"}`,
+			`{"email":"nobody"}`,
+		},
+		{
+			"drop array elements",
+			Config{Mode: Drop, Fields: []Field{{Path: "tags[]"}}},
+			`{"tags":["jane@example.com","public","+1 415 555 2671"]}`,
+			`{"tags":["public"]}`,
+		},
+		{
+			"drop in array of objects",
+			Config{Mode: Drop, Fields: []Field{{Path: "files[].title"}}},
+			`{"files":[{"title":"jane@example.com","id":"F1"},{"title":"notes","id":"F2"}]}`,
+			`{"files":[{"id":"F1"},{"title":"notes","id":"F2"}]}`,
+		},
+		{
+			"selected detectors and all",
+			Config{Detectors: []string{"email"}, Fields: []Field{{Path: "text"}, {Path: "name", Detectors: []string{"all"}}}},
+			`{"text":"jane@example.com +1 415 555 2671","name":"Jane Doe"}`,
+			`{"text":"[REDACTED:email] +1 415 555 2671","name":"[REDACTED:all]"}`,
+		},
+		{
+			"custom pattern",
+			Config{Detectors: []string{"ticket"}, Patterns: []Pattern{{Name: "ticket", Regex: `TCK-\d+`}}},
+			`{"text":"see TCK-42 from jane@example.com"}`,
+			`{"text":"see [REDACTED:ticket] from jane@example.com"}`,
+		},
+		{
+			"other logtypes",
+			Config{Fields: []Field{{Path: "text", LogTypes: []string{"SlackAuditLogs"}}}},
+			`{"text":"jane@example.com"}`,
+			`{"text":"jane@example.com"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := New(tt.config, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var record, want map[string]interface{}
+			if err := json.Unmarshal([]byte(tt.record), &record); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal([]byte(tt.want), &want); err != nil {
+				t.Fatal(err)
+			}
+			if !r.Process("SlackMessages", record) {
+				t.Fatal("record was dropped")
+			}
+			if !reflect.DeepEqual(record, want) {
+				got, _ := json.Marshal(record)
+				t.Errorf("record = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHash(t *testing.T) {
+	redact := func(salt string, record map[string]interface{}) string {
+		r, err := New(Config{Mode: Hash, Fields: []Field{{Path: "text"}, {Path: "files[].name"}}}, salt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Process("SlackMessages", record)
+		return record["text"].(string)
+	}
+	first := redact("salt", map[string]interface{}{"text": "jane@example.com"})
+	if !strings.HasPrefix(first, "[email:") || strings.Contains(first, "jane") {
+		t.Fatalf("hash = %s", first)
+	}
+	if second := redact("salt", map[string]interface{}{"text": "jane@example.com"}); second != first {
+		t.Errorf("equal values got different hashes %s and %s", first, second)
+	}
+	if other := redact("other", map[string]interface{}{"text": "jane@example.com"}); other == first {
+		t.Error("different salts got equal hashes")
+	}
+	files := []interface{}{map[string]interface{}{"name": "jane@example.com"}}
+	redact("salt", map[string]interface{}{"text": "", "files": files})
+	if name := files[0].(map[string]interface{})["name"]; name != first {
+		t.Errorf("hash in array = %v, want %s", name, first)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		salt   string
+	}{
+		{"invalid mode", Config{Mode: "erase"}, ""},
+		{"invalid field mode", Config{Fields: []Field{{Path: "text", Mode: "erase"}}}, ""},
+		{"hash without salt", Config{Mode: Hash}, ""},
+		{"unknown detector", Config{Detectors: []string{"passport"}}, ""},
+		{"invalid pattern", Config{Patterns: []Pattern{{Name: "p", Regex: "("}}}, ""},
+		{"pattern named all", Config{Patterns: []Pattern{{Name: "all", Regex: "x"}}}, ""},
+		{"field without path", Config{Fields: []Field{{Mode: Mask}}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.config, tt.salt); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
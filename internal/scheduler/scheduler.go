@@ -0,0 +1,49 @@
+package scheduler
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Scheduler runs the collectors of all workspaces on their polling intervals.
+// Iterations of the same collector never overlap, not even across workspaces,
+// because collectors keep the logs pending export in package level buffers.
+type Scheduler struct {
+	mux   sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+func NewScheduler() *Scheduler {
+	return &Scheduler{locks: make(map[string]*sync.Mutex)}
+}
+
+func (s *Scheduler) collectorLock(collector string) *sync.Mutex {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	lock, ok := s.locks[collector]
+	if !ok {
+		lock = &sync.Mutex{}
+		s.locks[collector] = lock
+	}
+	return lock
+}
+
+// Schedule runs job right away and then once per interval. An iteration which takes
+// longer than the interval delays the next one instead of running concurrently.
+func (s *Scheduler) Schedule(collector string, workspace string, interval time.Duration, job func(iteration int)) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		iteration := 1
+		for {
+			lock := s.collectorLock(collector)
+			lock.Lock()
+			job(iteration)
+			lock.Unlock()
+			<-ticker.C
+			iteration++
+			slog.Info("Starting polling iteration for", "logType", collector, "workspace", workspace, "iteration", iteration)
+		}
+	}()
+}
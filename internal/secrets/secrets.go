@@ -0,0 +1,138 @@
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	envScheme  = "env://"
+	fileScheme = "file://"
+	redacted   = "[REDACTED]"
+)
+
+// Secret is a credential read from a literal, an environment variable or a file.
+// File secrets are re-read when the file changes, so rotated Kubernetes secrets
+// mounted as files are picked up without a restart. Formatting, logging and
+// marshaling a Secret never reveal its value.
+type Secret struct {
+	mux     sync.Mutex
+	source  string // Description of where the value comes from, safe to log
+	path    string // Set for file secrets
+	value   string
+	modTime time.Time
+}
+
+// Parse resolves a secret reference from SlackConfig.yaml: env://NAME, file:///path/to/secret,
+// or a literal value
+func Parse(ref string) (*Secret, error) {
+	switch {
+	case strings.HasPrefix(ref, envScheme):
+		name := strings.TrimPrefix(ref, envScheme)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return nil, fmt.Errorf("environment variable %s referenced by %s is not set", name, ref)
+		}
+		return &Secret{source: ref, value: strings.TrimSpace(value)}, nil
+	case strings.HasPrefix(ref, fileScheme):
+		return fromFile(strings.TrimPrefix(ref, fileScheme))
+	case ref == "":
+		return nil, errors.New("empty secret reference")
+	}
+	return &Secret{source: "config", value: ref}, nil
+}
+
+// FromEnv returns the secret stored in the file named by NAME_FILE, or the value of NAME.
+// It returns nil when neither variable is set.
+func FromEnv(name string) (*Secret, error) {
+	if path, ok := os.LookupEnv(name + "_FILE"); ok && path != "" {
+		return fromFile(path)
+	}
+	if value, ok := os.LookupEnv(name); ok && value != "" {
+		return &Secret{source: envScheme + name, value: strings.TrimSpace(value)}, nil
+	}
+	return nil, nil
+}
+
+func fromFile(path string) (*Secret, error) {
+	s := &Secret{source: fileScheme + path, path: path}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// reload re-reads a file secret when its modification time changed, the caller holds mux
+func (s *Secret) reload() error {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return fmt.Errorf("error reading secret %s: %v", s.source, err)
+	}
+	if info.ModTime().Equal(s.modTime) && s.value != "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("error reading secret %s: %v", s.source, err)
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "" {
+		return fmt.Errorf("secret %s is empty", s.source)
+	}
+	if s.value != "" && s.value != value {
+		slog.Info("Secret changed, using the new value", "source", s.source)
+	}
+	s.value = value
+	s.modTime = info.ModTime()
+	return nil
+}
+
+// Value returns the secret, re-reading file secrets which changed since the last read.
+// When a file can not be read the previous value is kept.
+func (s *Secret) Value() string {
+	if s == nil {
+		return ""
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.path != "" {
+		if err := s.reload(); err != nil {
+			slog.Error("Not able to re-read secret, using the previous value", "error", err)
+		}
+	}
+	return s.value
+}
+
+// Source describes where the secret is read from without revealing it
+func (s *Secret) Source() string {
+	if s == nil {
+		return ""
+	}
+	return s.source
+}
+
+func (s *Secret) String() string {
+	return redacted
+}
+
+func (s *Secret) GoString() string {
+	return redacted
+}
+
+func (s *Secret) LogValue() slog.Value {
+	return slog.StringValue(redacted)
+}
+
+func (s *Secret) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + redacted + `"`), nil
+}
+
+func (s *Secret) MarshalYAML() (interface{}, error) {
+	return redacted, nil
+}
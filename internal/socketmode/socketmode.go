@@ -0,0 +1,144 @@
+package socketmode
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"slackLogs/internal/common"
+	"slackLogs/internal/secrets"
+)
+
+const (
+	dialTimeout = 15 * time.Second
+	// Slack pings Socket Mode connections, no frame for this long means the connection is gone
+	idleTimeout      = 2 * time.Minute
+	maxReconnectWait = 2 * time.Minute
+)
+
+// connectionsOpenResponse contains slack API successful response
+// https://api.slack.com/methods/apps.connections.open#examples
+type connectionsOpenResponse struct {
+	Ok       bool   `json:"ok"`
+	URL      string `json:"url"`
+	ReqError string `json:"error"`
+}
+
+// envelope wraps every message Slack sends over a Socket Mode connection
+// https://api.slack.com/apis/connections/socket#events
+type envelope struct {
+	Type         string          `json:"type"`
+	EnvelopeID   string          `json:"envelope_id"`
+	Payload      json.RawMessage `json:"payload"`
+	Reason       string          `json:"reason"` // Set for disconnect messages
+	RetryAttempt int             `json:"retry_attempt"`
+}
+
+// acknowledgement is sent back for every envelope, otherwise Slack redelivers it
+type acknowledgement struct {
+	EnvelopeID string `json:"envelope_id"`
+}
+
+// PayloadHandler processes the Events API payload of an events_api envelope, e.g. events.Processor
+type PayloadHandler interface {
+	HandlePayload(payload []byte) error
+}
+
+// Client receives events over Socket Mode, which needs no inbound connectivity,
+// and feeds them to the same processor as the Events API receiver
+// https://api.slack.com/apis/connections/socket
+type Client struct {
+	appToken           *secrets.Secret
+	connectionsOpenURL string
+	processor          PayloadHandler
+	sleep              func(time.Duration) // Waits between failed connection attempts
+}
+
+func NewClient(appToken *secrets.Secret, connectionsOpenURL string, processor PayloadHandler) *Client {
+	return &Client{appToken: appToken, connectionsOpenURL: connectionsOpenURL, processor: processor, sleep: time.Sleep}
+}
+
+// Run connects and keeps reconnecting, it never returns
+func (c *Client) Run() {
+	wait := time.Second
+	for {
+		err := c.connectAndServe()
+		if err == nil {
+			// Slack asked us to reconnect
+			wait = time.Second
+			continue
+		}
+		slog.Error("Socket Mode connection failed, reconnecting", "in", wait.String(), "error", err)
+		c.sleep(wait)
+		wait = wait * 2
+		if wait > maxReconnectWait {
+			wait = maxReconnectWait
+		}
+	}
+}
+
+// openConnection requests a new WebSocket URL with the app-level token
+func (c *Client) openConnection() (string, error) {
+	slackClient := common.NewSlackClient(c.connectionsOpenURL, c.appToken.Value(), "")
+	var responseData connectionsOpenResponse
+	errSlack := slackClient.SendRequest(common.WaitAndRetry, &responseData)
+	if errSlack != nil {
+		return "", errSlack
+	}
+	if !responseData.Ok {
+		return "", fmt.Errorf("Slack API error %v", responseData.ReqError)
+	}
+	return responseData.URL, nil
+}
+
+// connectAndServe handles envelopes of one connection. It returns nil when
+// Slack sends a disconnect message and a new connection should be opened.
+func (c *Client) connectAndServe() error {
+	wsURL, err := c.openConnection()
+	if err != nil {
+		return err
+	}
+	conn, err := dial(wsURL, dialTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	slog.Info("Socket Mode connection established")
+
+	for {
+		message, err := conn.ReadMessage(idleTimeout)
+		if err != nil {
+			return err
+		}
+		var env envelope
+		if err = json.Unmarshal(message, &env); err != nil {
+			slog.Warn("Ignoring invalid Socket Mode message", "error", err)
+			continue
+		}
+		// Acknowledge first, Slack expects it within 3 seconds
+		if env.EnvelopeID != "" {
+			ack, _ := json.Marshal(acknowledgement{EnvelopeID: env.EnvelopeID})
+			if err = conn.WriteMessage(ack); err != nil {
+				return err
+			}
+		}
+		switch env.Type {
+		case "hello":
+			slog.Debug("Socket Mode hello received")
+		case "disconnect":
+			slog.Info("Socket Mode disconnect requested, reconnecting", "reason", env.Reason)
+			if env.Reason == "link_disabled" {
+				return errors.New("Socket Mode is disabled for this app")
+			}
+			return nil
+		case "events_api":
+			if err = c.processor.HandlePayload(env.Payload); err != nil {
+				slog.Error("Not able to process Socket Mode event", "envelopeId", env.EnvelopeID, "error", err)
+			}
+		default:
+			slog.Debug("Ignoring Socket Mode envelope", "type", env.Type)
+		}
+	}
+}
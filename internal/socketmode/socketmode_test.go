@@ -0,0 +1,296 @@
+package socketmode
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"slackLogs/internal/secrets"
+)
+
+const testTimeout = 10 * time.Second
+
+// loadEnvelopes reads recorded envelopes from a file with one JSON envelope per line
+func loadEnvelopes(t *testing.T, path string) [][]byte {
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var envelopes [][]byte
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), maxMessageSize)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) > 0 {
+			envelopes = append(envelopes, append([]byte(nil), line...))
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return envelopes
+}
+
+func envelopeIDs(t *testing.T, envelopes [][]byte) []string {
+	var ids []string
+	for _, recorded := range envelopes {
+		var env envelope
+		if err := json.Unmarshal(recorded, &env); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, env.EnvelopeID)
+	}
+	return ids
+}
+
+// replayServer is a local stand-in for Slack's Socket Mode endpoints. It answers
+// apps.connections.open and replays the recorded envelopes to the first connection,
+// collecting the acknowledgements, then asks the client to reconnect.
+type replayServer struct {
+	envelopes [][]byte
+	failOpens map[int]bool // Numbers of the apps.connections.open requests which fail
+	listener  net.Listener
+	server    *http.Server
+	replayed  chan struct{} // Closed after the disconnect message was sent
+	connected chan int      // Receives the number of each connection after the hello message
+
+	mux         sync.Mutex
+	opens       int
+	connections int
+	acks        []string
+}
+
+func newReplayServer(t *testing.T, envelopes [][]byte, failOpens ...int) *replayServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &replayServer{
+		envelopes: envelopes,
+		failOpens: make(map[int]bool),
+		listener:  listener,
+		replayed:  make(chan struct{}),
+		connected: make(chan int, 10),
+	}
+	for _, n := range failOpens {
+		s.failOpens[n] = true
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/apps.connections.open", s.connectionsOpen)
+	mux.HandleFunc("/link", s.link)
+	s.server = &http.Server{Handler: mux, ReadHeaderTimeout: testTimeout}
+	go s.server.Serve(listener)
+	t.Cleanup(func() { s.server.Close() })
+	return s
+}
+
+func (s *replayServer) connectionsOpenURL() string {
+	return "http://" + s.listener.Addr().String() + "/api/apps.connections.open"
+}
+
+func (s *replayServer) Acks() []string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return append([]string(nil), s.acks...)
+}
+
+func (s *replayServer) Opens() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.opens
+}
+
+func (s *replayServer) connectionsOpen(w http.ResponseWriter, r *http.Request) {
+	s.mux.Lock()
+	s.opens++
+	fail := s.failOpens[s.opens]
+	s.mux.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	if fail {
+		json.NewEncoder(w).Encode(connectionsOpenResponse{Ok: false, ReqError: "internal_error"})
+		return
+	}
+	json.NewEncoder(w).Encode(connectionsOpenResponse{Ok: true, URL: "ws://" + s.listener.Addr().String() + "/link"})
+}
+
+func (s *replayServer) link(w http.ResponseWriter, r *http.Request) {
+	conn, err := accept(w, r)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	if err = conn.WriteMessage([]byte(`{"type":"hello","num_connections":1}`)); err != nil {
+		return
+	}
+
+	s.mux.Lock()
+	s.connections++
+	n := s.connections
+	s.mux.Unlock()
+	s.connected <- n
+	if n > 1 {
+		// Keep later connections open and idle, like Slack without new events
+		for {
+			if _, err = conn.ReadMessage(idleTimeout); err != nil {
+				return
+			}
+		}
+	}
+
+	for _, recorded := range s.envelopes {
+		if err = conn.WriteMessage(recorded); err != nil {
+			return
+		}
+		message, err := conn.ReadMessage(testTimeout)
+		if err != nil {
+			return
+		}
+		var ack acknowledgement
+		if json.Unmarshal(message, &ack) == nil {
+			s.mux.Lock()
+			s.acks = append(s.acks, ack.EnvelopeID)
+			s.mux.Unlock()
+		}
+	}
+	conn.WriteMessage([]byte(`{"type":"disconnect","reason":"refresh_requested"}`))
+	close(s.replayed)
+	conn.ReadMessage(testTimeout)
+}
+
+// recordingHandler stands in for events.Processor
+type recordingHandler struct {
+	mux      sync.Mutex
+	payloads []string
+}
+
+func (h *recordingHandler) HandlePayload(payload []byte) error {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	var callback struct {
+		EventID string `json:"event_id"`
+	}
+	json.Unmarshal(payload, &callback)
+	h.payloads = append(h.payloads, callback.EventID)
+	return nil
+}
+
+func (h *recordingHandler) EventIDs() []string {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	return append([]string(nil), h.payloads...)
+}
+
+// startClient runs a client against the stand-in. Its waits between connection attempts are
+// sent to the returned channel instead of sleeping, and block once the test has ended.
+func startClient(t *testing.T, s *replayServer, handler PayloadHandler) <-chan time.Duration {
+	appToken, err := secrets.Parse("xapp-1-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sleeps := make(chan time.Duration)
+	done := make(chan struct{})
+	t.Cleanup(func() { close(done) })
+	c := NewClient(appToken, s.connectionsOpenURL(), handler)
+	c.sleep = func(wait time.Duration) {
+		select {
+		case sleeps <- wait:
+		case <-done:
+			select {}
+		}
+	}
+	go c.Run()
+	return sleeps
+}
+
+func waitFor(t *testing.T, ch <-chan struct{}, what string) {
+	select {
+	case <-ch:
+	case <-time.After(testTimeout):
+		t.Fatalf("timed out waiting for %s", what)
+	}
+}
+
+func TestAcknowledgesReplayedEnvelopes(t *testing.T) {
+	envelopes := loadEnvelopes(t, "testdata/envelopes.jsonl")
+	s := newReplayServer(t, envelopes)
+	handler := &recordingHandler{}
+	startClient(t, s, handler)
+	waitFor(t, s.replayed, "the replay")
+
+	if acks, want := s.Acks(), envelopeIDs(t, envelopes); !reflect.DeepEqual(acks, want) {
+		t.Errorf("acknowledged envelopes %v, want %v", acks, want)
+	}
+	// Only events_api envelopes reach the processor
+	if ids, want := handler.EventIDs(), []string{"Ev01", "Ev02"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("processed events %v, want %v", ids, want)
+	}
+}
+
+func TestReconnectsAfterDisconnect(t *testing.T) {
+	s := newReplayServer(t, loadEnvelopes(t, "testdata/envelopes.jsonl"))
+	sleeps := startClient(t, s, &recordingHandler{})
+	for want := 1; want <= 2; want++ {
+		select {
+		case n := <-s.connected:
+			if n != want {
+				t.Fatalf("connection %d, want %d", n, want)
+			}
+		case wait := <-sleeps:
+			t.Fatalf("client waited %v before reconnecting, a disconnect message reconnects right away", wait)
+		case <-time.After(testTimeout):
+			t.Fatalf("timed out waiting for connection %d", want)
+		}
+	}
+	if opens := s.Opens(); opens != 2 {
+		t.Errorf("apps.connections.open called %d times, want 2", opens)
+	}
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	// Three failed attempts, a connection which Slack ends with a disconnect message, then another failure
+	s := newReplayServer(t, nil, 1, 2, 3, 5)
+	sleeps := startClient(t, s, &recordingHandler{})
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, time.Second}
+	for i, w := range want {
+		select {
+		case wait := <-sleeps:
+			if wait != w {
+				t.Fatalf("wait %d is %v, want %v", i+1, wait, w)
+			}
+		case <-time.After(testTimeout):
+			t.Fatalf("timed out waiting for wait %d", i+1)
+		}
+	}
+}
+
+func TestReconnectBackoffIsCapped(t *testing.T) {
+	var failOpens []int
+	for n := 1; n <= 20; n++ {
+		failOpens = append(failOpens, n)
+	}
+	s := newReplayServer(t, nil, failOpens...)
+	sleeps := startClient(t, s, &recordingHandler{})
+	wait := time.Second
+	for i := 0; i < 10; i++ {
+		select {
+		case got := <-sleeps:
+			if got != wait {
+				t.Fatalf("wait %d is %v, want %v", i+1, got, wait)
+			}
+		case <-time.After(testTimeout):
+			t.Fatalf("timed out waiting for wait %d", i+1)
+		}
+		wait = wait * 2
+		if wait > maxReconnectWait {
+			wait = maxReconnectWait
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Store is a small key/value store for collector checkpoints and snapshots. Values are
+// persisted as a JSON file in the state directory when Save is called. Without a state
+// directory values are kept in memory only, and collectors start over after a restart.
+type Store struct {
+	mux    sync.Mutex
+	path   string
+	values map[string]json.RawMessage
+	dirty  bool
+}
+
+var (
+	storesMux sync.Mutex
+	stores    = make(map[string]*Store)
+	directory string
+)
+
+// SetDirectory sets the directory state files are kept in, it is called once at startup
+func SetDirectory(dir string) {
+	storesMux.Lock()
+	defer storesMux.Unlock()
+	directory = dir
+}
+
+// Open returns the store with the given name, loading it from the state directory the
+// first time. Collectors of several workspaces share the store of the same name.
+func Open(name string) (*Store, error) {
+	storesMux.Lock()
+	defer storesMux.Unlock()
+	if s, ok := stores[name]; ok {
+		return s, nil
+	}
+	s := &Store{values: make(map[string]json.RawMessage)}
+	if directory != "" {
+		if err := os.MkdirAll(directory, 0700); err != nil {
+			return nil, err
+		}
+		s.path = filepath.Join(directory, name+".json")
+		data, err := ioutil.ReadFile(s.path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("error reading state file %s: %v", s.path, err)
+		}
+		if len(data) > 0 {
+			if err = json.Unmarshal(data, &s.values); err != nil {
+				return nil, fmt.Errorf("error parsing state file %s: %v", s.path, err)
+			}
+		}
+	}
+	stores[name] = s
+	return s, nil
+}
+
+// Get decodes the value of key into v and reports whether the key exists
+func (s *Store) Get(key string, v interface{}) (bool, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	data, ok := s.values[key]
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+// Set stores v under key, it is persisted with the next Save
+func (s *Store) Set(key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.values[key] = data
+	s.dirty = true
+	return nil
+}
+
+func (s *Store) Delete(key string) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if _, ok := s.values[key]; ok {
+		delete(s.values, key)
+		s.dirty = true
+	}
+}
+
+// Keys returns the keys starting with prefix in sorted order
+func (s *Store) Keys(prefix string) []string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	var keys []string
+	for k := range s.values {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Save writes changed values to the state file
+func (s *Store) Save() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.path == "" || !s.dirty {
+		return nil
+	}
+	data, err := json.Marshal(s.values)
+	if err != nil {
+		return err
+	}
+	// Write to a temporary file first so a crash never leaves a truncated state file behind
+	tmp := s.path + ".tmp"
+	if err = ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
+
+// DiffSets compares two snapshots and returns the values only in current (added)
+// and only in previous (removed), in the order they appear
+func DiffSets(previous []string, current []string) (added []string, removed []string) {
+	previousSet := make(map[string]bool, len(previous))
+	for _, v := range previous {
+		previousSet[v] = true
+	}
+	currentSet := make(map[string]bool, len(current))
+	for _, v := range current {
+		currentSet[v] = true
+		if !previousSet[v] {
+			added = append(added, v)
+		}
+	}
+	for _, v := range previous {
+		if !currentSet[v] {
+			removed = append(removed, v)
+		}
+	}
+	return added, removed
+}
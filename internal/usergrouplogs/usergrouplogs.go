@@ -0,0 +1,135 @@
+package usergrouplogs
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"slackLogs/internal/common"
+	"slackLogs/internal/constants"
+	"slackLogs/internal/logclient"
+	"slackLogs/internal/model"
+	"slackLogs/internal/state"
+)
+
+var (
+	logtype       = "UserGroup"
+	memberLogtype = "UserGroupMember"
+	changeLogtype = "UserGroupChange"
+)
+
+type UserGroupLogsHandler struct {
+	Client *logclient.LogClient
+	batch  *logclient.SnapshotBatch // Snapshots are team id/user group id -> sorted member ids
+}
+
+func NewUserGroupLogsHandler(client *logclient.LogClient) (*UserGroupLogsHandler, error) {
+	snapshots, err := state.Open("userGroups")
+	if err != nil {
+		return nil, err
+	}
+	return &UserGroupLogsHandler{Client: client, batch: logclient.NewSnapshotBatch(client, snapshots)}, nil
+}
+
+// userGroupsListResponse contains slack API successful response
+// https://api.slack.com/methods/usergroups.list#examples
+type userGroupsListResponse struct {
+	Ok         bool                   `json:"ok"`
+	UserGroups []model.UserGroup      `json:"usergroups"`
+	ReqError   string                 `json:"error"`
+	Random     map[string]interface{} `json:"-"`
+}
+
+func getSlackUserGroups(token string, teamId string) (userGroupsListResponse, error) {
+	slackClient := common.NewSlackClient(constants.SlackUserGroupsAPIURL, token, "")
+	params := map[string]string{
+		"team_id":          teamId,
+		"include_users":    "true",
+		"include_disabled": "true",
+	}
+	var responseData userGroupsListResponse
+	errSlack := slackClient.SendRequest(common.WaitAndRetry, &responseData, params)
+	if errSlack != nil {
+		return responseData, errSlack
+	}
+	if !responseData.Ok {
+		return responseData, fmt.Errorf("Slack API error %v", responseData.ReqError)
+	}
+	return responseData, nil
+}
+
+// transformUserGroupLogs adds the group definition, a record per member and, when a previous
+// snapshot exists, a change record per member who was added or removed since then
+func transformUserGroupLogs(batch *logclient.SnapshotBatch, g model.UserGroup, previous []string, hasPrevious bool, teamName string) error {
+	ts := time.Now().Unix()
+	g.TeamName = teamName
+	g.MemberCount = len(g.Users)
+	if err := batch.Add(logtype, ts, g); err != nil {
+		return err
+	}
+	for _, user := range g.Users {
+		err := batch.Add(memberLogtype, ts, model.UserGroupMember{UserGroupID: g.ID, Handle: g.Handle, UserID: user, TeamName: teamName, SnapshotTime: ts})
+		if err != nil {
+			return err
+		}
+	}
+	if !hasPrevious {
+		return nil
+	}
+	added, removed := state.DiffSets(previous, g.Users)
+	for _, user := range added {
+		err := batch.Add(changeLogtype, ts, model.UserGroupChange{UserGroupID: g.ID, Handle: g.Handle, UserID: user, Change: "added", TeamName: teamName, SnapshotTime: ts})
+		if err != nil {
+			return err
+		}
+	}
+	for _, user := range removed {
+		err := batch.Add(changeLogtype, ts, model.UserGroupChange{UserGroupID: g.ID, Handle: g.Handle, UserID: user, Change: "removed", TeamName: teamName, SnapshotTime: ts})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ug *UserGroupLogsHandler) ResetLogs() {
+	if err := ug.batch.Export(); err != nil {
+		slog.Error("Not able to store user group snapshots", "error", err)
+	}
+}
+
+func (ug *UserGroupLogsHandler) Collect(token string, teamId string, teamName string) error {
+	slog.Info("Collecting user groups", "team", teamName)
+	response, err := getSlackUserGroups(token, teamId)
+	if err != nil {
+		return err
+	}
+	for _, g := range response.UserGroups {
+		if g.Users == nil {
+			g.Users = []string{}
+		}
+		sort.Strings(g.Users)
+		key := teamId + "/" + g.ID
+		previous, hasPrevious, err := ug.batch.Previous(key)
+		if err != nil {
+			return err
+		}
+		err = transformUserGroupLogs(ug.batch, g, previous, hasPrevious, teamName)
+		if err != nil {
+			return err
+		}
+		ug.batch.SetSnapshot(key, g.Users)
+		// Check total collected logs size and maximum allowed logs size in a single request
+		if ug.batch.Full() {
+			if err = ug.batch.Export(); err != nil {
+				return err
+			}
+		}
+	}
+	// Flush rest of the logs
+	if err = ug.batch.Export(); err != nil {
+		return err
+	}
+	return ug.batch.Save()
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 
 	"slackLogs/internal/common"
+	"slackLogs/internal/directory"
 	"slackLogs/internal/logclient"
 	"slackLogs/internal/model"
 	"slackLogs/internal/constants"
@@ -23,17 +24,31 @@ var slackToken string
 
 type UserLogsHandler struct {
 	Client *logclient.LogClient
+	// When false, users are only collected for the directory and not exported
+	ExportLogs bool
+	// Refreshed with the users of each team when set
+	Directory *directory.Directory
+	// Set when team.billableInfo can't be used with the token, users are then exported without billable status
+	billableUnavailable bool
 }
 
-func NewUserLogsHandler(client *logclient.LogClient) *UserLogsHandler {
-	return &UserLogsHandler{Client: client}
+func NewUserLogsHandler(client *logclient.LogClient, dir *directory.Directory, exportLogs bool) *UserLogsHandler {
+	return &UserLogsHandler{Client: client, Directory: dir, ExportLogs: exportLogs}
+}
+
+// member is a users.list member with the profile email, which the directory needs and UserLog leaves out
+type member struct {
+	model.User
+	Profile struct {
+		Email string `json:"email"`
+	} `json:"profile"`
 }
 
 // usersListResponse contains slack API successful response
 // https://api.slack.com/methods/users.list#examples
 type usersListResponse struct {
 	Ok               bool         `json:"ok"`
-	UsersList        []model.User `json:"members"`
+	UsersList        []member     `json:"members"`
 	ResponseMetaData struct {
 		NextCursor string `json:"next_cursor"`
 	} `json:"response_metadata"`
@@ -41,14 +56,6 @@ type usersListResponse struct {
 	Random            map[string]interface{} `json:"-"`
 }
 
-// teamInfoResponse contains slack API successful response
-// https://api.slack.com/methods/team.info#examples
-type TeamInfoResponse struct {
-	Ok               bool                   `json:"ok"`
-	TeamInfo         model.Team             `json:"team"`
-	Random           map[string]interface{} `json:"-"`
-}
-
 
 type billingInfo struct {
 	BillingActive bool `json:"billing_active"`
@@ -60,9 +67,21 @@ type billingInfo struct {
 type BillableInfoResponse struct {
 	Ok                bool                        `json:"ok"`
 	BillableInfo      map[string]billingInfo      `json:"billable_info"`
+	ResponseMetaData struct {
+		NextCursor string `json:"next_cursor"`
+	} `json:"response_metadata"`
 	ReqError          string                      `json:"error"`
 }
 
+// Errors of team.billableInfo which will not go away on the next iteration
+var billableInfoPermanentErrors = map[string]bool{
+	"not_allowed_token_type": true,
+	"missing_scope":          true,
+	"not_authed":             true,
+	"paid_only":              true,
+	"not_allowed":            true,
+}
+
 
 func getSlackUserLogs(c *common.SlackClient, teamId string) (usersListResponse, error) {
 	slackClient := common.NewSlackClient(c.SlackAPIURL, c.SlackToken, c.Cursor)
@@ -80,24 +99,25 @@ func getSlackUserLogs(c *common.SlackClient, teamId string) (usersListResponse,
 	return responseData, nil
 }
 
-func transformUserLogs(userLogs []model.User, teamName string) error {
+// transformUserLogs adds the billable status from billable, which is nil when it is unknown
+func transformUserLogs(client *logclient.LogClient, members []member, teamName string, billable map[string]bool) error {
 	ts := time.Now().Unix()
-	for _, l := range userLogs {
+	for _, m := range members {
+		l := m.User
 		l.TeamName = teamName
-		// TODO: Disabling this call as a fix for "not_allowed_token_type" error message
-		/*status, err := getBillableInfo(l.UserID)
-		if err != nil {
-			l.Billable = status
-		}*/
+		if billable != nil {
+			status := billable[l.UserID]
+			l.Billable = &status
+		}
 		data, errJson := json.Marshal(l)
-		totalLogsSize = totalLogsSize + len(data)
 		if errJson != nil {
 			return errJson
 		}
-		lm := logclient.Logs{
-			Timestamp: ts,
-			Message:   string(data),
+		lm, keep := client.NewLogs(logtype, ts, data)
+		if !keep {
+			continue
 		}
+		totalLogsSize = totalLogsSize + len(lm.Message)
 		logCount = logCount + 1
 		logs = append(logs, lm)
 	}
@@ -105,7 +125,7 @@ func transformUserLogs(userLogs []model.User, teamName string) error {
 }
 
 func (ul *UserLogsHandler) ResetLogs() {
-	if len(logs) > 0 {
+	if len(logs) > 0 && ul.ExportLogs {
 		ul.Client.Flush(logtype, logs)
 	}
 	logs = []logclient.Logs{}
@@ -114,43 +134,66 @@ func (ul *UserLogsHandler) ResetLogs() {
 }
 
 
-func getBillableInfo(user string) (bool, error) {
-	slackClient := common.NewSlackClient(constants.SlackBillingInfoAPIURL, slackToken, "")
-	params := map[string]string{
-        	"user": user,
-    	}
-	var responseData BillableInfoResponse
-        errSlack := slackClient.SendRequest(common.WaitAndRetry, &responseData, params)
-        if errSlack != nil {
-                return false, errSlack
-        }
-	if !responseData.Ok {
-                return false, fmt.Errorf("Slack API error %v", responseData.ReqError)
-        }
-	_, billingStatus := responseData.BillableInfo[user]
-        return billingStatus, nil
+// getBillableInfo returns the billable status of every user of the team, the second
+// result reports whether the error will occur again on the next iteration
+func getBillableInfo(teamId string) (map[string]bool, bool, error) {
+	billable := make(map[string]bool)
+	nextCursor := ""
+	for {
+		slackClient := common.NewSlackClient(constants.SlackBillingInfoAPIURL, slackToken, nextCursor)
+		params := map[string]string{
+			"team_id": teamId,
+			"limit":   "1000",
+		}
+		var responseData BillableInfoResponse
+		errSlack := slackClient.SendRequest(common.WaitAndRetry, &responseData, params)
+		if errSlack != nil {
+			return nil, false, errSlack
+		}
+		if !responseData.Ok {
+			return nil, billableInfoPermanentErrors[responseData.ReqError], fmt.Errorf("Slack API error %v", responseData.ReqError)
+		}
+		for user, info := range responseData.BillableInfo {
+			billable[user] = info.BillingActive
+		}
+		nextCursor = responseData.ResponseMetaData.NextCursor
+		if nextCursor == "" {
+			return billable, false, nil
+		}
+	}
 }
 
-
-func getTeamName() (string, error) {
-	slackClient := common.NewSlackClient(constants.SlackTeamInfoAPIURL, slackToken, "")
-	var responseData model.TeamInfoResponse
-	errSlack := slackClient.SendRequest(common.WaitAndRetry, &responseData)
-	if errSlack != nil {
-		return "", errSlack
+// teamBillableInfo fetches the billable status of the team's users once per iteration.
+// It returns nil when the status is not available, e.g. for bot tokens.
+func (ul *UserLogsHandler) teamBillableInfo(teamId string) map[string]bool {
+	if ul.billableUnavailable {
+		return nil
 	}
-	if !responseData.Ok {
-                return "", fmt.Errorf("Slack API error %v", responseData.ReqError)
-        }
-	slog.Debug("getTeamName", "teamName" , responseData.TeamInfo.Name)
-	return responseData.TeamInfo.Name, nil
+	// team.billableInfo only accepts user tokens, other tokens get not_allowed_token_type once
+	billable, permanent, err := getBillableInfo(teamId)
+	if err != nil && permanent {
+		slog.Warn("Billable status of users is not available with this token, it needs a user token with admin scope. Exporting users without it", "error", err)
+		ul.billableUnavailable = true
+		return nil
+	}
+	if err != nil {
+		slog.Warn("Not able to get the billable status of users, exporting users without it", "error", err)
+		return nil
+	}
+	return billable
 }
 
+
 func (ul *UserLogsHandler) Collect(token string, teamId string, teamName string) error {
 	slog.Info("Collecting user logs")
 	nextCursor := ""
 	logCount = 0
 	slackToken = token
+	var billable map[string]bool
+	if ul.ExportLogs {
+		billable = ul.teamBillableInfo(teamId)
+	}
+	var users []directory.User
 	for {
 		c := common.NewSlackClient(constants.SlackUserAPIURL, slackToken, nextCursor)
 		// Get User logs
@@ -159,10 +202,13 @@ func (ul *UserLogsHandler) Collect(token string, teamId string, teamName string)
 			return err
 		}
 		// Filter required fields and add timestamp to each log
-		err = transformUserLogs(response.UsersList, teamName)
+		err = transformUserLogs(ul.Client, response.UsersList, teamName, billable)
 		if err != nil {
 			return err
 		}
+		for _, m := range response.UsersList {
+			users = append(users, directory.User{ID: m.UserID, Name: m.Name, RealName: m.RealName, Email: m.Profile.Email})
+		}
 		// Check total collected logs size and maximum allowed logs size in a single request
 		if totalLogsSize >= constants.MaxAllowed {
 			ul.ResetLogs()
@@ -174,6 +220,9 @@ func (ul *UserLogsHandler) Collect(token string, teamId string, teamName string)
 		}
 		nextCursor = next
 	}
+	if ul.Directory != nil {
+		ul.Directory.SetUsers(teamId, users)
+	}
 	// Flush rest of the logs
 	ul.ResetLogs()
 	return nil
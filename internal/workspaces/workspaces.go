@@ -0,0 +1,96 @@
+package workspaces
+
+import (
+	"fmt"
+	"log/slog"
+
+	"slackLogs/internal/args"
+	"slackLogs/internal/auth"
+	"slackLogs/internal/common"
+	"slackLogs/internal/gridlogs"
+	"slackLogs/internal/logclient"
+	"slackLogs/internal/teamslist"
+)
+
+// Workspace is a configured Slack workspace (or Grid org) with its own token and log client
+type Workspace struct {
+	args.Workspace
+	LogClient   *logclient.LogClient
+	TeamsInfo   map[string]string // team id -> team name
+	// Set by DiscoverTeams from auth.test, Grid collectors need an org-level token
+	EnterpriseID        string
+	EnterpriseName      string // Name of the Grid org, auth.test reports it as the team of org-level tokens
+	IsEnterpriseInstall bool
+	tokenSource *common.TokenSource
+}
+
+// NewWorkspace resolves the workspace token and creates a log client which adds
+// the workspace name and its extra attributes to every exported log
+func NewWorkspace(config args.Workspace, sink *logclient.LogClient) (*Workspace, error) {
+	w := &Workspace{Workspace: config, TeamsInfo: make(map[string]string)}
+	// Token rotation: https://api.slack.com/authentication/rotation
+	if config.RefreshToken != nil {
+		ts, err := common.NewTokenSource(config.Token.Value(), config.RefreshToken.Value(), config.ClientId.Value(), config.ClientSecret, config.TokenStorePath)
+		if err != nil {
+			return nil, fmt.Errorf("not able to set up token rotation for workspace %s, please provide the client id and client secret: %v", config.Name, err)
+		}
+		w.tokenSource = ts
+	} else if config.Token == nil {
+		return nil, fmt.Errorf("please set SLACK_ACCESS_TOKEN (or the token of workspace %s)", config.Name)
+	}
+
+	attrs := map[string]string{"workspace": config.Name}
+	for k, v := range config.Attributes {
+		attrs[k] = v
+	}
+	w.LogClient = sink.WithAttributes(attrs)
+	return w, nil
+}
+
+// Token returns the current token, the latest one when token rotation is enabled.
+// Tokens read from files are re-read when the file changes.
+func (w *Workspace) Token() string {
+	if w.tokenSource != nil {
+		return w.tokenSource.Token()
+	}
+	return w.Workspace.Token.Value()
+}
+
+// DiscoverTeams collects the teams the token has access to. Org-level tokens of a Grid org
+// list the teams with admin.teams.list, other tokens with auth.teams.list, falling back
+// to the token's own team for tokens which are not org wide.
+func (w *Workspace) DiscoverTeams() error {
+	info, err := auth.GetAuthInfo(w.Token())
+	if err != nil {
+		return err
+	}
+	w.EnterpriseID = info.EnterpriseID
+	w.IsEnterpriseInstall = info.IsEnterpriseInstall
+	if w.IsEnterpriseInstall {
+		w.EnterpriseName = info.Team
+		gridTeams, err := gridlogs.GetGridTeams(w.Token())
+		if err == nil && len(gridTeams) > 0 {
+			for _, team := range gridTeams {
+				w.TeamsInfo[team.ID] = team.Name
+			}
+			return nil
+		}
+		slog.Warn("Not able to list the teams of the org with admin.teams.list, using auth.teams.list", "workspace", w.Name, "error", err)
+	}
+	teamsList, err := teamslist.GetSlackTeamList(w.Token())
+	if err != nil {
+		return err
+	}
+	if len(teamsList) > 0 {
+		for _, team := range teamsList {
+			w.TeamsInfo[team.Id] = team.Name
+		}
+		return nil
+	}
+	teamInfo, err := teamslist.GetSlackTeamInfo(w.Token())
+	if err != nil {
+		return err
+	}
+	w.TeamsInfo[teamInfo.Id] = teamInfo.Name
+	return nil
+}